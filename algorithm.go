@@ -0,0 +1,147 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"io"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"github.com/cloudflare/circl/sign/ed448"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Algorithm identifies the signature scheme used for a signature chain. The
+// master key, live key, and request signature all use the same Algorithm.
+type Algorithm string
+
+// The supported signature algorithms. Requests name their algorithm in the
+// X-Signature-Algorithm header; requests without the header use Ed25519.
+const (
+	Ed25519 Algorithm = "ed25519"
+	Ed448   Algorithm = "ed448"
+)
+
+// parseAlgorithm returns the Algorithm named by s, which is case insensitive.
+// An empty name is Ed25519.
+func parseAlgorithm(s string) (Algorithm, bool) {
+	switch a := Algorithm(strings.ToLower(strings.TrimSpace(s))); a {
+	case "":
+		return Ed25519, true
+	case Ed25519, Ed448:
+		return a, true
+	default:
+		return "", false
+	}
+}
+
+// publicKeySize returns the size in bytes of public keys for the algorithm.
+func (a Algorithm) publicKeySize() int {
+	if a == Ed448 {
+		return ed448.PublicKeySize
+	}
+
+	return ed25519.PublicKeySize
+}
+
+// signatureSize returns the size in bytes of signatures for the algorithm.
+func (a Algorithm) signatureSize() int {
+	if a == Ed448 {
+		return ed448.SignatureSize
+	}
+
+	return ed25519.SignatureSize
+}
+
+// validLengths reports whether the public key and value of sig have the sizes
+// of keys and signatures for the algorithm. The endorsement is not checked, as
+// it may hold a certificate.
+func (a Algorithm) validLengths(sig *Signature) bool {
+	return len(*sig.PublicKey) == a.publicKeySize() && len(*sig.Value) == a.signatureSize()
+}
+
+// verify reports whether sig is a valid signature of msg by pub, using the
+// algorithm. The zero Algorithm is Ed25519.
+func (a Algorithm) verify(pub, msg, sig []byte) bool {
+	if len(pub) != a.publicKeySize() {
+		return false
+	}
+
+	if a == Ed448 {
+		return ed448.Verify(ed448.PublicKey(pub), msg, sig, "")
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(pub), msg, sig)
+}
+
+// publicKey is a public key, tagged with the Algorithm it is used with.
+type publicKey struct {
+	alg Algorithm
+	key []byte
+}
+
+// ed25519Keys tags each of keys as an Ed25519 publicKey.
+func ed25519Keys(keys []ed25519.PublicKey) []publicKey {
+	pks := make([]publicKey, len(keys))
+	for i, k := range keys {
+		pks[i] = publicKey{alg: Ed25519, key: k}
+	}
+	return pks
+}
+
+// isZero reports whether pk holds no key.
+func (pk publicKey) isZero() bool {
+	return pk.key == nil
+}
+
+// equal reports whether pk and o are the same key of the same Algorithm.
+func (pk publicKey) equal(o publicKey) bool {
+	return pk.alg == o.alg && bytes.Equal(pk.key, o.key)
+}
+
+// verify reports whether sig is a valid signature of msg by pk, if msg was
+// signed with alg.
+func (pk publicKey) verify(alg Algorithm, msg, sig []byte) bool {
+	return pk.alg == alg && alg.verify(pk.key, msg, sig)
+}
+
+// verifyEd25519Reader reports whether sig is a valid Ed25519 signature by pub
+// of prefix followed by the contents of body. Unlike verify, body is hashed as
+// it is read, rather than held in memory. It returns any error from reading
+// body.
+func verifyEd25519Reader(pub, prefix []byte, body io.Reader, sig []byte) (bool, error) {
+	// The message is hashed with the signature's R and the public key, as
+	// crypto/ed25519 does, then checked against the signature's S.
+	h := sha512.New()
+	if len(sig) == ed25519.SignatureSize {
+		h.Write(sig[:32])
+	}
+	h.Write(pub)
+	h.Write(prefix)
+	if _, err := io.Copy(h, body); err != nil {
+		return false, err
+	}
+
+	if len(pub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize || sig[63]&224 != 0 {
+		return false, nil
+	}
+
+	A, err := new(edwards25519.Point).SetBytes(pub)
+	if err != nil {
+		return false, nil
+	}
+
+	k, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		return false, nil
+	}
+
+	S, err := edwards25519.NewScalar().SetCanonicalBytes(sig[32:])
+	if err != nil {
+		return false, nil
+	}
+
+	minusA := new(edwards25519.Point).Negate(A)
+	R := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(k, minusA, S)
+	return bytes.Equal(sig[:32], R.Bytes()), nil
+}
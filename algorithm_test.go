@@ -0,0 +1,139 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/ed448"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+func TestEd448(t *testing.T) {
+	master := ed448.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed448.SeedSize))
+	live := ed448.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed448.SeedSize))
+	livePub := live.Public().(ed448.PublicKey)
+	masterKey := base64.New(master.Public().(ed448.PublicKey)).String()
+
+	newEd448Req := func(alg string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "host date")
+		req.Header.Set("X-Signature-Algorithm", alg)
+
+		b, _ := Canonize(req, bytes.NewBufferString("body"))
+		sig := &Signature{
+			Value:       base64.New(ed448.Sign(live, b, "")),
+			PublicKey:   base64.New(livePub),
+			Endorsement: base64.New(ed448.Sign(master, livePub, "")),
+		}
+		req.Header.Set("X-Signature", sig.String())
+		return req
+	}
+
+	v, err := NewVerifierWithAlgorithm(Ed448, masterKey)
+	if err != nil {
+		t.Fatal("could not create verifier:", err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, alg := range []string{"ed448", "Ed448"} {
+			if err := v.Verify(newEd448Req(alg), bytes.NewBufferString("body")); err != nil {
+				t.Errorf("expected %s signature to verify, got: %s", alg, err)
+			}
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		if err := v.Verify(newEd448Req("ed448"), bytes.NewBufferString("tampered")); err == nil {
+			t.Error("expected tampered body to fail verification")
+		}
+	})
+
+	t.Run("algorithm mismatch", func(t *testing.T) {
+		err := v.Verify(newEd448Req(""), bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 401 {
+			t.Error("expected a 401 Error, got:", err)
+		}
+
+		keys := newTestKeys()
+		err = keys.verifier(t).Verify(newEd448Req("ed448"), bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 401 {
+			t.Error("expected a 401 Error, got:", err)
+		}
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		err := v.Verify(newEd448Req("rsa"), bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Error("expected a 400 Error, got:", err)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := NewVerifierWithAlgorithm(Ed448, ManifoldKey); err != ErrInvalidPublicKey {
+			t.Error("expected an Ed25519 key to be rejected, got:", err)
+		}
+	})
+	t.Run("key algorithm", func(t *testing.T) {
+		if v.pk.alg != Ed448 {
+			t.Error("expected master key to be tagged as Ed448, got:", v.pk.alg)
+		}
+
+		endorsement := ed448.Sign(master, livePub, "")
+		if !v.pk.verify(Ed448, livePub, endorsement) {
+			t.Error("expected Ed448 endorsement to verify")
+		}
+		if v.pk.verify(Ed25519, livePub, endorsement) {
+			t.Error("expected Ed448 key not to verify an Ed25519 signature")
+		}
+	})
+}
+
+func TestVerifyEd25519Reader(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	pub := key.Public().(ed25519.PublicKey)
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+
+	head, body := "put /v1/resources\n", strings.Repeat("body", 10000)
+	sig := ed25519.Sign(key, []byte(head+body))
+
+	highS := append([]byte(nil), sig...)
+	highS[63] |= 0x80
+
+	tcs := []struct {
+		name  string
+		pub   []byte
+		head  string
+		body  string
+		sig   []byte
+		valid bool
+	}{
+		{"valid", pub, head, body, sig, true},
+		{"split differently", pub, head + "body", body[4:], sig, true},
+		{"altered body", pub, head, body + "x", sig, false},
+		{"other key", other, head, body, sig, false},
+		{"non-canonical signature", pub, head, body, highS, false},
+		{"short signature", pub, head, body, sig[:32], false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := verifyEd25519Reader(tc.pub, []byte(tc.head), strings.NewReader(tc.body), tc.sig)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if ok != tc.valid {
+				t.Errorf("expected valid to be %t, got %t", tc.valid, ok)
+			}
+
+			if std := len(tc.sig) == ed25519.SignatureSize && ed25519.Verify(tc.pub, []byte(tc.head+tc.body), tc.sig); std != ok {
+				t.Errorf("expected result to match crypto/ed25519, which gave %t", std)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// AuditOutcome is the result of handling a request, as recorded in an
+// AuditEntry.
+type AuditOutcome string
+
+// The outcomes of handling a request.
+const (
+	// AuditVerified is the outcome of a request whose signature was valid.
+	AuditVerified AuditOutcome = "verified"
+	// AuditRejected is the outcome of a request that was rejected.
+	AuditRejected AuditOutcome = "rejected"
+	// AuditUnsigned is the outcome of an unsigned request passed to the
+	// handler set by WithUnsignedHandler.
+	AuditUnsigned AuditOutcome = "unsigned"
+)
+
+// AuditEntry describes the handling of a single request by the Verifier's
+// middleware.
+type AuditEntry struct {
+	Time    time.Time // When the middleware received the request.
+	Method  string
+	Path    string
+	Outcome AuditOutcome
+
+	// Reason is the message of the error the request was rejected with, and
+	// empty otherwise.
+	Reason string
+
+	// KeyFingerprint identifies the live public key the request was signed
+	// with, if its signature could be parsed. It is the hex encoded prefix of
+	// the key's SHA-256 digest, and does not imply the key was endorsed
+	// unless the request was verified.
+	KeyFingerprint string
+
+	// Latency is the time taken to handle the request before it was passed
+	// on or rejected.
+	Latency time.Duration
+}
+
+// WithAuditLog configures the Verifier's middleware to call fn with an
+// AuditEntry for each request it handles, once the request is verified or
+// rejected, and before it is passed on. fn is called synchronously, and
+// should not block.
+func WithAuditLog(fn func(AuditEntry)) Option {
+	return func(v *Verifier) {
+		v.auditLog = fn
+	}
+}
+
+// audit records the handling of req, started at start, with the Verifier's
+// audit log, if it has one.
+func (v *Verifier) audit(start time.Time, req *http.Request, outcome AuditOutcome, sig *Signature, e *Error) {
+	if v.auditLog == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:    start,
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Outcome: outcome,
+		Latency: time.Since(start),
+	}
+
+	if e != nil {
+		entry.Reason = e.Message
+	}
+
+	if sig != nil && sig.PublicKey != nil {
+		sum := sha256.Sum256(*sig.PublicKey)
+		entry.KeyFingerprint = hex.EncodeToString(sum[:8])
+	}
+
+	v.auditLog(entry)
+}
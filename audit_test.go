@@ -0,0 +1,104 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestWithAuditLog(t *testing.T) {
+	keys := newTestKeys()
+	sum := sha256.Sum256(keys.live.Public().(ed25519.PublicKey))
+	fingerprint := hex.EncodeToString(sum[:8])
+
+	var entries []AuditEntry
+	record := WithAuditLog(func(e AuditEntry) {
+		entries = append(entries, e)
+	})
+	handler := func(http.ResponseWriter, *http.Request) {}
+
+	tcs := []struct {
+		name     string
+		opts     []Option
+		modify   func(*http.Request)
+		expected AuditEntry
+	}{
+		{
+			name: "verified",
+			expected: AuditEntry{
+				Method:         "PUT",
+				Path:           "/v1/resources",
+				Outcome:        AuditVerified,
+				KeyFingerprint: fingerprint,
+			},
+		},
+		{
+			name: "bad signature",
+			modify: func(r *http.Request) {
+				r.Body = ioutil.NopCloser(strings.NewReader("other"))
+			},
+			expected: AuditEntry{
+				Method:         "PUT",
+				Path:           "/v1/resources",
+				Outcome:        AuditRejected,
+				Reason:         "Request was not signed by included Public Key",
+				KeyFingerprint: fingerprint,
+			},
+		},
+		{
+			name:   "missing signature",
+			modify: func(r *http.Request) { r.Header.Del("X-Signature") },
+			expected: AuditEntry{
+				Method:  "PUT",
+				Path:    "/v1/resources",
+				Outcome: AuditRejected,
+				Reason:  "Missing X-Signature header",
+			},
+		},
+		{
+			name:   "unsigned",
+			opts:   []Option{WithUnsignedHandler(http.HandlerFunc(handler))},
+			modify: func(r *http.Request) { r.Header.Del("X-Signature") },
+			expected: AuditEntry{
+				Method:  "PUT",
+				Path:    "/v1/resources",
+				Outcome: AuditUnsigned,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			entries = nil
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+			if tc.modify != nil {
+				tc.modify(req)
+			}
+
+			before := time.Now()
+			v := keys.verifier(t, append(tc.opts, record)...)
+			v.WrapFunc(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(entries) != 1 {
+				t.Fatal("expected one audit entry, got:", len(entries))
+			}
+
+			e := entries[0]
+			if e.Time.Before(before) || e.Latency < 0 {
+				t.Errorf("unexpected timing: %s, %s", e.Time, e.Latency)
+			}
+
+			e.Time, e.Latency = time.Time{}, 0
+			if e != tc.expected {
+				t.Errorf("unexpected audit entry: %+v", e)
+			}
+		})
+	}
+}
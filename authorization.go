@@ -0,0 +1,165 @@
+package signature
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// authorizationScheme is the Authorization header scheme for packed signature
+// data.
+const authorizationScheme = "Manifold"
+
+// WithAuthorizationHeader configures the Verifier to accept signature data
+// packed into a single Authorization header, for environments that constrain
+// the headers a client can send:
+//
+//	Authorization: Manifold date="2017-03-05T23:53:08Z", headers="host date", sig="..."
+//
+// The date, headers, and sig parameters are used as the Date, X-Signed-Headers
+// and X-Signature headers respectively, including in the canonical form. Any
+// of these headers already present on the request take precedence. The
+// Authorization header itself can not be signed.
+func WithAuthorizationHeader() Option {
+	return func(v *Verifier) {
+		v.authorizationHeader = true
+	}
+}
+
+// authorizationParams maps packed Authorization parameters to the headers they
+// stand in for.
+var authorizationParams = map[string]string{
+	"date":    "Date",
+	"headers": "X-Signed-Headers",
+	"sig":     "X-Signature",
+}
+
+// unpackAuthorization returns a copy of req with the signature data from its
+// Authorization header unpacked into the corresponding headers. If the request
+// has no Manifold Authorization header, req is returned unchanged.
+func unpackAuthorization(req *http.Request) (*http.Request, error) {
+	auth := req.Header.Get("Authorization")
+	i := strings.IndexByte(auth, ' ')
+	if i < 0 || !strings.EqualFold(auth[:i], authorizationScheme) {
+		return req, nil
+	}
+
+	params, err := parseAuthParams(auth[i+1:])
+	if err != nil {
+		return nil, &Error{Code: 400, Message: "Could not parse Authorization header", category: Malformed}
+	}
+
+	h := make(http.Header, len(req.Header)+len(authorizationParams))
+	for k, vs := range req.Header {
+		h[k] = vs
+	}
+
+	for p, name := range authorizationParams {
+		if v, ok := params[p]; ok && h.Get(name) == "" {
+			h.Set(name, v)
+		}
+	}
+
+	r := *req
+	r.Header = h
+	return &r, nil
+}
+
+// WithCombinedSignatureHeader configures the Verifier to accept an X-Signature
+// header that also carries the signed headers list, for compact formats used
+// by constrained clients:
+//
+//	X-Signature: headers="host date", sig="..."
+//
+// The headers and sig parameters are used as the X-Signed-Headers and
+// X-Signature headers respectively, including in the canonical form. The
+// combined form is only read when the X-Signed-Headers header is absent.
+func WithCombinedSignatureHeader() Option {
+	return func(v *Verifier) {
+		v.combinedSignature = true
+	}
+}
+
+// unpackCombinedSignature returns a copy of req with the signed headers list
+// and signature from its combined X-Signature header unpacked into the
+// corresponding headers. If the request has an X-Signed-Headers header, or its
+// X-Signature header is not in the combined form, req is returned unchanged.
+func unpackCombinedSignature(req *http.Request) (*http.Request, error) {
+	if _, ok := req.Header["X-Signed-Headers"]; ok {
+		return req, nil
+	}
+
+	// Encoded signatures never contain an equals sign, as they are unpadded.
+	combined := req.Header.Get("X-Signature")
+	if !strings.Contains(combined, "=") {
+		return req, nil
+	}
+
+	params, err := parseAuthParams(combined)
+	if err != nil {
+		return nil, &Error{Code: 400, Message: "Could not parse X-Signature header", category: Malformed}
+	}
+
+	if _, ok := params["headers"]; !ok {
+		return nil, &Error{Code: 400, Message: "Missing X-Signed-Headers header", category: Malformed}
+	}
+
+	h := make(http.Header, len(req.Header)+1)
+	for k, vs := range req.Header {
+		h[k] = vs
+	}
+	h.Set("X-Signed-Headers", params["headers"])
+	h.Set("X-Signature", params["sig"])
+
+	r := *req
+	r.Header = h
+	return &r, nil
+}
+
+// parseAuthParams parses a comma delimited list of name=value parameters.
+// Values may be quoted, allowing them to contain spaces and commas. Names are
+// lowercased.
+func parseAuthParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return params, nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, errors.New("missing parameter value")
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted value")
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+
+		if _, ok := params[name]; ok {
+			return nil, errors.New("duplicate parameter " + name)
+		}
+		params[name] = value
+
+		s = strings.TrimLeft(s, " \t")
+		if s != "" {
+			if s[0] != ',' {
+				return nil, errors.New("expected a comma between parameters")
+			}
+			s = s[1:]
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthParams(t *testing.T) {
+	tcs := []struct {
+		in       string
+		expected map[string]string
+	}{
+		{``, map[string]string{}},
+		{`date=2017-03-05T23:53:08Z`, map[string]string{"date": "2017-03-05T23:53:08Z"}},
+		{`Date="a, b", headers="host date" ,sig=x`, map[string]string{"date": "a, b", "headers": "host date", "sig": "x"}},
+		{`a=1,`, map[string]string{"a": "1"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			params, err := parseAuthParams(tc.in)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if !reflect.DeepEqual(params, tc.expected) {
+				t.Errorf("unexpected params: %v", params)
+			}
+		})
+	}
+
+	for _, in := range []string{`a`, `=1`, `a="1`, `a="1" b=2`, `a=1, a=2`} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := parseAuthParams(in); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestWithAuthorizationHeader(t *testing.T) {
+	keys := newTestKeys()
+
+	packed := func() *http.Request {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Header.Set("Authorization", `Manifold date="`+req.Header.Get("Date")+
+			`", headers="`+req.Header.Get("X-Signed-Headers")+
+			`", sig="`+req.Header.Get("X-Signature")+`"`)
+		req.Header.Del("Date")
+		req.Header.Del("X-Signed-Headers")
+		req.Header.Del("X-Signature")
+		return req
+	}
+
+	t.Run("packed", func(t *testing.T) {
+		req := packed()
+		v := keys.verifier(t, WithAuthorizationHeader())
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if req.Header.Get("X-Signature") != "" {
+			t.Error("request headers were modified")
+		}
+	})
+
+	t.Run("other scheme", func(t *testing.T) {
+		req := packed()
+		req.Header.Set("Authorization", "Bearer token")
+
+		err := keys.verifier(t, WithAuthorizationHeader()).Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Message != "Missing X-Signature header" {
+			t.Error("expected missing signature error, got:", err)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		req := packed()
+		req.Header.Set("Authorization", `Manifold sig="unterminated`)
+
+		err := keys.verifier(t, WithAuthorizationHeader()).Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Error("expected a 400 Error, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		if err := keys.verifier(t).Verify(packed(), bytes.NewBufferString("body")); err == nil {
+			t.Error("expected packed signature to be rejected by default")
+		}
+	})
+}
+
+func TestWithCombinedSignatureHeader(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithCombinedSignatureHeader())
+
+	combined := func() *http.Request {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Header.Set("X-Signature", `headers="`+req.Header.Get("X-Signed-Headers")+
+			`", sig="`+req.Header.Get("X-Signature")+`"`)
+		req.Header.Del("X-Signed-Headers")
+		return req
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		req := combined()
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if req.Header.Get("X-Signed-Headers") != "" {
+			t.Error("request headers were modified")
+		}
+	})
+
+	t.Run("separate headers", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("altered headers list", func(t *testing.T) {
+		req := combined()
+		req.Header.Set("X-Signature", strings.Replace(req.Header.Get("X-Signature"), "host date", "date", 1))
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err == nil {
+			t.Error("expected altered headers list to fail verification")
+		}
+	})
+
+	tcs := []struct {
+		name    string
+		header  string
+		message string
+	}{
+		{"missing headers", `sig="abc"`, "Missing X-Signed-Headers header"},
+		{"unparseable", `headers="host date, sig=abc`, "Could not parse X-Signature header"},
+		{"missing sig", `headers="host date"`, "Missing X-Signature header"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := combined()
+			req.Header.Set("X-Signature", tc.header)
+
+			err := v.Verify(req, bytes.NewBufferString("body"))
+			if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != tc.message {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		err := keys.verifier(t).Verify(combined(), bytes.NewBufferString("body"))
+		if err == nil {
+			t.Error("expected combined header to be rejected by default")
+		}
+	})
+}
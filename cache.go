@@ -0,0 +1,96 @@
+package signature
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	endorsementCacheSize = 1024
+	endorsementCacheTTL  = time.Minute
+)
+
+// endorsementCache is an LRU cache of successful endorsement checks, keyed on
+// the live public key and its endorsement. Each entry records the master key
+// that made the endorsement, so that entries are only used while that key
+// remains trusted.
+type endorsementCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type endorsement struct {
+	key     string
+	master  publicKey
+	expires time.Time
+}
+
+func newEndorsementCache(size int, ttl time.Duration) *endorsementCache {
+	return &endorsementCache{
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func endorsementKey(sig *Signature) string {
+	return string(*sig.PublicKey) + string(*sig.Endorsement)
+}
+
+// get returns the master key that endorsed sig's public key, if it is cached,
+// unexpired, and one of masters.
+func (c *endorsementCache) get(sig *Signature, masters []publicKey, now time.Time) (publicKey, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[endorsementKey(sig)]
+	if !ok {
+		return publicKey{}, false
+	}
+
+	e := el.Value.(*endorsement)
+	if now.After(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, e.key)
+		return publicKey{}, false
+	}
+
+	for _, pk := range masters {
+		if pk.equal(e.master) {
+			c.lru.MoveToFront(el)
+			return e.master, true
+		}
+	}
+
+	// The endorsing key is no longer trusted.
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	return publicKey{}, false
+}
+
+// add records that master endorsed sig's public key.
+func (c *endorsementCache) add(sig *Signature, master publicKey, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := endorsementKey(sig)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*endorsement)
+		e.master, e.expires = master, now.Add(c.ttl)
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&endorsement{key: key, master: master, expires: now.Add(c.ttl)})
+	for c.lru.Len() > c.size {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*endorsement).key)
+	}
+}
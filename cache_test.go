@@ -0,0 +1,165 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+// mutableKeys is a KeySource whose keys may be changed.
+type mutableKeys struct {
+	mu   sync.Mutex
+	keys []ed25519.PublicKey
+}
+
+func (m *mutableKeys) Keys() []ed25519.PublicKey {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys
+}
+
+func (m *mutableKeys) set(keys ...ed25519.PublicKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = keys
+}
+
+func TestEndorsementCache(t *testing.T) {
+	keys := newTestKeys()
+
+	t.Run("cached", func(t *testing.T) {
+		v := keys.verifier(t)
+		for i := 0; i < 2; i++ {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+			if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+				t.Fatal("expected signature to verify, got:", err)
+			}
+		}
+
+		if v.endorsements.lru.Len() != 1 {
+			t.Error("expected a single cached endorsement, got:", v.endorsements.lru.Len())
+		}
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		if err := v.Verify(req, bytes.NewBufferString("tampered")); err == nil {
+			t.Error("expected tampered body to fail with a cached endorsement")
+		}
+	})
+
+	t.Run("different endorsement", func(t *testing.T) {
+		v := keys.verifier(t)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Fatal("expected signature to verify, got:", err)
+		}
+
+		sig, _ := ParseSignature(req.Header.Get("X-Signature"))
+		forged := append([]byte(nil), *sig.Endorsement...)
+		forged[0] ^= 0xFF
+		sig.Endorsement = base64.New(forged)
+		req.Header.Set("X-Signature", sig.String())
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err == nil {
+			t.Error("expected forged endorsement to fail verification")
+		}
+	})
+
+	t.Run("key change", func(t *testing.T) {
+		src := &mutableKeys{}
+		src.set(keys.master.Public().(ed25519.PublicKey))
+
+		v, _ := NewVerifier("PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk", WithKeySource(src))
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Fatal("expected signature to verify, got:", err)
+		}
+
+		src.set()
+		if err := v.Verify(req, bytes.NewBufferString("body")); err == nil {
+			t.Error("expected cached endorsement to be invalidated when its key was removed")
+		}
+
+		if v.endorsements.lru.Len() != 0 {
+			t.Error("expected invalidated endorsement to be evicted")
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		c := newEndorsementCache(2, time.Minute)
+		sig, _ := ParseSignature(testSignature)
+		master := publicKey{alg: Ed25519, key: keys.master.Public().(ed25519.PublicKey)}
+		masters := []publicKey{master}
+		now := time.Now()
+
+		c.add(sig, master, now)
+		if _, ok := c.get(sig, masters, now.Add(time.Minute)); !ok {
+			t.Error("expected endorsement to be cached")
+		}
+
+		if _, ok := c.get(sig, masters, now.Add(time.Minute+time.Second)); ok {
+			t.Error("expected endorsement to expire")
+		}
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		c := newEndorsementCache(2, time.Minute)
+		master := publicKey{alg: Ed25519, key: keys.master.Public().(ed25519.PublicKey)}
+		masters := []publicKey{master}
+		now := time.Now()
+
+		var sigs []*Signature
+		for i := byte(0); i < 3; i++ {
+			sig, _ := ParseSignature(testSignature)
+			(*sig.PublicKey)[0] = i
+			sigs = append(sigs, sig)
+		}
+
+		c.add(sigs[0], master, now)
+		c.add(sigs[1], master, now)
+		c.get(sigs[0], masters, now) // nolint: errcheck
+		c.add(sigs[2], master, now)
+
+		if _, ok := c.get(sigs[1], masters, now); ok {
+			t.Error("expected least recently used endorsement to be evicted")
+		}
+
+		if _, ok := c.get(sigs[0], masters, now); !ok {
+			t.Error("expected recently used endorsement to be cached")
+		}
+	})
+}
+
+func BenchmarkVerify(b *testing.B) {
+	keys := newTestKeys()
+	req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("X-Signed-Headers", "host date")
+	c, _ := Canonize(req, bytes.NewBufferString("body"))
+	keys.sign(req, c)
+
+	for _, cached := range []bool{false, true} {
+		name := "uncached"
+		if cached {
+			name = "cached"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			v, _ := NewVerifier(keys.masterKey())
+			if !cached {
+				v.endorsements = nil
+			}
+
+			for i := 0; i < b.N; i++ {
+				if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
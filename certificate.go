@@ -0,0 +1,67 @@
+package signature
+
+import (
+	"bytes"
+	stded25519 "crypto/ed25519"
+	"crypto/x509"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// WithCertificateEndorsement configures the Verifier to treat the endorsement
+// component of the signature as a DER encoded X.509 certificate, rather than a
+// raw signature over the live public key.
+//
+// The certificate must be signed by a trusted master key using Ed25519, must
+// be within its validity period, and must embed the Ed25519 live public key
+// included in the signature.
+func WithCertificateEndorsement() Option {
+	return func(v *Verifier) {
+		v.certEndorsement = true
+	}
+}
+
+// validateCert validates sig against b, where the signature's endorsement is a
+// certificate.
+func (v *Verifier) validateCert(sig *Signature, b []byte) error {
+	if err := v.checkCert(sig); err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(*sig.PublicKey), b, []byte(*sig.Value)) {
+		return &Error{Code: 401, Message: "Request was not signed by included Public Key", category: Unauthorized, cause: ErrBadSignature}
+	}
+
+	return nil
+}
+
+// checkCert checks that the certificate in sig's endorsement is valid, and
+// endorses sig's public key.
+func (v *Verifier) checkCert(sig *Signature) error {
+	cert, err := x509.ParseCertificate([]byte(*sig.Endorsement))
+	if err != nil {
+		return &Error{Code: 400, Message: "Could not parse endorsement certificate", category: Malformed}
+	}
+
+	livePubKey, ok := cert.PublicKey.(stded25519.PublicKey)
+	if !ok || !bytes.Equal(livePubKey, []byte(*sig.PublicKey)) {
+		return &Error{Code: 401, Message: "Endorsement certificate does not match request Public Key", category: Unauthorized}
+	}
+
+	now := v.clock()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return &Error{Code: 401, Message: "Endorsement certificate is expired or not yet valid", category: Unauthorized}
+	}
+
+	if cert.SignatureAlgorithm != x509.PureEd25519 {
+		return &Error{Code: 401, Message: "Request Public Key was not endorsed by Manifold", category: Unauthorized, cause: ErrUnendorsedKey}
+	}
+
+	for _, pk := range v.masters() {
+		if pk.verify(Ed25519, cert.RawTBSCertificate, cert.Signature) {
+			return nil
+		}
+	}
+
+	return &Error{Code: 401, Message: "Request Public Key was not endorsed by Manifold", category: Unauthorized, cause: ErrUnendorsedKey}
+}
@@ -0,0 +1,95 @@
+package signature
+
+import (
+	"bytes"
+	stded25519 "crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+// certify returns a DER encoded certificate for the live key, signed by the
+// master key.
+func (k *testKeys) certify(t *testing.T, notBefore, notAfter time.Time) []byte {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "live"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	parent := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "master"},
+	}
+
+	live := stded25519.PublicKey(k.live.Public().(ed25519.PublicKey))
+	master := stded25519.PrivateKey(k.master)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, live, master)
+	if err != nil {
+		t.Fatal("could not create certificate:", err)
+	}
+
+	return der
+}
+
+func TestWithCertificateEndorsement(t *testing.T) {
+	keys := newTestKeys()
+
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	clock := WithClock(func() time.Time { return now })
+
+	newCertReq := func(cert []byte) *http.Request {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		sig, err := ParseSignature(req.Header.Get("X-Signature"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig.Endorsement = base64.New(cert)
+		req.Header.Set("X-Signature", sig.String())
+		return req
+	}
+
+	t.Run("valid certificate", func(t *testing.T) {
+		req := newCertReq(keys.certify(t, now.Add(-time.Hour), now.Add(time.Hour)))
+		v := keys.verifier(t, WithCertificateEndorsement(), clock)
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("expired certificate", func(t *testing.T) {
+		req := newCertReq(keys.certify(t, now.Add(-2*time.Hour), now.Add(-time.Hour)))
+		v := keys.verifier(t, WithCertificateEndorsement(), clock)
+		err := v.Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Message != "Endorsement certificate is expired or not yet valid" {
+			t.Error("expected expired certificate error, got:", err)
+		}
+	})
+
+	t.Run("untrusted master", func(t *testing.T) {
+		req := newCertReq(keys.certify(t, now.Add(-time.Hour), now.Add(time.Hour)))
+		v, _ := NewVerifier("PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk", WithCertificateEndorsement(), clock)
+		err := v.Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 401 {
+			t.Error("expected a 401 Error, got:", err)
+		}
+	})
+
+	t.Run("raw endorsement", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		v := keys.verifier(t, WithCertificateEndorsement(), clock)
+		err := v.Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Error("expected a 400 Error, got:", err)
+		}
+	})
+}
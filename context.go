@@ -0,0 +1,15 @@
+package signature
+
+import "context"
+
+// signatureKey is the context key for a verified request's Signature.
+type signatureKey struct{}
+
+// SignatureFromContext returns the Signature of a request verified by the
+// Verifier's middleware, from the request's context. It returns false if the
+// request was not verified, such as when it was passed to a handler set by
+// WithUnsignedHandler.
+func SignatureFromContext(ctx context.Context) (*Signature, bool) {
+	sig, ok := ctx.Value(signatureKey{}).(*Signature)
+	return sig, ok && sig != nil
+}
@@ -0,0 +1,66 @@
+package signature
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestWrapSignatureContext(t *testing.T) {
+	keys := newTestKeys()
+
+	r := keys.verifier(t).WrapFunc(func(rw http.ResponseWriter, req *http.Request) {
+		sig, ok := SignatureFromContext(req.Context())
+		if !ok {
+			t.Error("expected signature in request context")
+		} else if !bytes.Equal(*sig.PublicKey, keys.live.Public().(ed25519.PublicKey)) {
+			t.Error("unexpected signature public key:", sig.PublicKey)
+		}
+
+		b, _ := ioutil.ReadAll(req.Body)
+		rw.Write([]byte(path.Base(req.URL.Path) + ":" + string(b)))
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources/abc", "body")
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+
+		if rw.Code != 200 {
+			t.Fatal("unexpected response code:", rw.Code, rw.Body.String())
+		}
+
+		if rw.Body.String() != "abc:body" {
+			t.Error("unexpected body:", rw.Body.String())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources/abc", "body")
+		req.Body = ioutil.NopCloser(bytes.NewBufferString("other"))
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+
+		if rw.Code != 401 {
+			t.Error("unexpected response code:", rw.Code)
+		}
+
+		if rw.Body.String() != `{"message":"Request was not signed by included Public Key"}` {
+			t.Error("unexpected body:", rw.Body.String())
+		}
+	})
+}
+
+func TestSignatureFromContext(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+	if _, ok := SignatureFromContext(req.Context()); ok {
+		t.Error("expected no signature for unverified request")
+	}
+}
@@ -0,0 +1,32 @@
+package signature
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecompressBody returns a reader of the request's decompressed body if its
+// Content-Encoding is gzip, and the request's body unchanged otherwise.
+//
+// Verify checks the signature against exactly the bytes read from the body it
+// is given, so whether to verify the compressed or decompressed body depends
+// on which the signer signed. DecompressBody allows callers that expect the
+// decompressed form to obtain it. The returned reader reads from req.Body.
+func DecompressBody(req *http.Request) (io.Reader, error) {
+	if req.Body == nil {
+		return http.NoBody, nil
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(req.Header.Get("Content-Encoding")), "gzip") {
+		return req.Body, nil
+	}
+
+	zr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		return nil, &Error{Code: 400, Message: "Unable to decompress request body", category: Malformed}
+	}
+
+	return zr, nil
+}
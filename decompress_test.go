@@ -0,0 +1,68 @@
+package signature
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecompressBody(t *testing.T) {
+	keys := newTestKeys()
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	t.Run("gzip", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Body = ioutil.NopCloser(bytes.NewReader(gz.Bytes()))
+
+		r, err := DecompressBody(req)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, r); err != nil {
+			t.Error("expected decompressed body to verify, got:", err)
+		}
+	})
+
+	t.Run("compressed bytes", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		req.Header.Set("Content-Encoding", "gzip")
+
+		if err := keys.verifier(t).Verify(req, bytes.NewReader(gz.Bytes())); err == nil {
+			t.Error("expected compressed body to fail verification")
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		req.Body = ioutil.NopCloser(strings.NewReader(body))
+
+		r, err := DecompressBody(req)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, r); err != nil {
+			t.Error("expected body to verify, got:", err)
+		}
+	})
+
+	t.Run("invalid gzip", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", strings.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		_, err := DecompressBody(req)
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Error("expected malformed body error, got:", err)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DiffCanonical compares the canonical form of req, built as the Verifier
+// builds it with its configured Options, line by line against expected, such
+// as the canonical form logged by a signer. It returns a description of each
+// line that differs, or nil if they match.
+//
+// This is intended for debugging signatures that fail to verify, as the
+// differing lines identify the headers, target or body that changed between
+// signing and verification. An error reading body is reported as a difference.
+func (v *Verifier) DiffCanonical(req *http.Request, body io.Reader, expected []byte) []string {
+	actual, err := v.canon.canonize(req, body)
+	if err != nil {
+		return []string{fmt.Sprintf("could not canonicalize request: %s", err)}
+	}
+
+	al := bytes.Split(actual, []byte("\n"))
+	el := bytes.Split(expected, []byte("\n"))
+
+	var diffs []string
+	for i := 0; i < len(al) || i < len(el); i++ {
+		switch {
+		case i >= len(al):
+			diffs = append(diffs, fmt.Sprintf("line %d: expected %q, got no line", i+1, el[i]))
+		case i >= len(el):
+			diffs = append(diffs, fmt.Sprintf("line %d: expected no line, got %q", i+1, al[i]))
+		case !bytes.Equal(al[i], el[i]):
+			diffs = append(diffs, fmt.Sprintf("line %d: expected %q, got %q", i+1, el[i], al[i]))
+		}
+	}
+
+	return diffs
+}
@@ -0,0 +1,69 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDiffCanonical(t *testing.T) {
+	v := newTestKeys().verifier(t)
+
+	newDiffReq := func(contentType string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Signed-Headers", "host date content-type")
+		return req
+	}
+
+	expected, _ := Canonize(newDiffReq("application/json"), bytes.NewBufferString("{}"))
+
+	tcs := []struct {
+		name     string
+		req      *http.Request
+		body     string
+		expected []string
+	}{
+		{"matching", newDiffReq("application/json"), "{}", nil},
+		{
+			"differing header",
+			newDiffReq("application/json; charset=utf-8"),
+			"{}",
+			[]string{`line 4: expected "content-type: application/json", got "content-type: application/json; charset=utf-8"`},
+		},
+		{
+			"extra body line",
+			newDiffReq("application/json"),
+			"{}\n[]",
+			[]string{`line 7: expected no line, got "[]"`},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			diffs := v.DiffCanonical(tc.req, bytes.NewBufferString(tc.body), expected)
+			if !reflect.DeepEqual(diffs, tc.expected) {
+				t.Errorf("unexpected diff: %q", diffs)
+			}
+		})
+	}
+
+	t.Run("verifier options", func(t *testing.T) {
+		req := newDiffReq("application/json")
+		req.URL.RawQuery = "b=2&a=1"
+		canon := &canonOptions{preSortedQuery: true}
+		expected, _ := canon.canonize(req, bytes.NewBufferString("{}"))
+
+		pv := newTestKeys().verifier(t, WithPreSortedQuery())
+		if diffs := pv.DiffCanonical(req, bytes.NewBufferString("{}"), expected); diffs != nil {
+			t.Errorf("unexpected diff: %q", diffs)
+		}
+
+		diffs := v.DiffCanonical(req, bytes.NewBufferString("{}"), expected)
+		if want := []string{`line 1: expected "put /v1/resources?b=2&a=1", got "put /v1/resources?a=1&b=2"`}; !reflect.DeepEqual(diffs, want) {
+			t.Errorf("unexpected diff: %q", diffs)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	stdbase64 "encoding/base64"
+	"io"
+	"strings"
+)
+
+// checkDigest returns an error if the SHA-256 digest of body does not match
+// the one listed in the RFC 3230 Digest header value digest.
+func checkDigest(digest string, body io.Reader) error {
+	var expected []byte
+	for _, d := range strings.Split(digest, ",") {
+		i := strings.IndexByte(d, '=')
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(d[:i]), "SHA-256") {
+			continue
+		}
+
+		var err error
+		if expected, err = stdbase64.StdEncoding.DecodeString(strings.TrimSpace(d[i+1:])); err != nil {
+			return &Error{Code: 400, Message: "Could not parse Digest header", category: Malformed}
+		}
+		break
+	}
+
+	if expected == nil {
+		return &Error{Code: 400, Message: "Digest header has no SHA-256 digest", category: Malformed}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return &Error{Code: 401, Message: "Request body does not match Digest header", category: Unauthorized}
+	}
+
+	return nil
+}
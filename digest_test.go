@@ -0,0 +1,78 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestWithBodyDigest(t *testing.T) {
+	keys := newTestKeys()
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+	sum := sha256.Sum256([]byte(body))
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	// newDigestReq returns a request signed over its headers, including the
+	// Digest header, but not its body.
+	newDigestReq := func(digest string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Digest", digest)
+		req.Header.Set("X-Signed-Headers", "host date digest")
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	v := keys.verifier(t, WithBodyDigest())
+
+	tcs := []struct {
+		name    string
+		digest  string
+		body    string
+		code    int
+		message string
+	}{
+		{"matching", digest, body, 0, ""},
+		{"multiple digests", "MD5=HUXZLQLMuI/KZ5KDcJPcOA==, " + digest, body, 0, ""},
+		{"lowercase algorithm", "sha-256=" + digest[len("SHA-256="):], body, 0, ""},
+		{"mismatching", digest, body + " ", 401, "Request body does not match Digest header"},
+		{"no sha-256 digest", "MD5=HUXZLQLMuI/KZ5KDcJPcOA==", body, 400, "Digest header has no SHA-256 digest"},
+		{"invalid encoding", "SHA-256=!!!", body, 400, "Could not parse Digest header"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Verify(newDigestReq(tc.digest), bytes.NewBufferString(tc.body))
+			if tc.code == 0 {
+				if err != nil {
+					t.Error("expected signature to verify, got:", err)
+				}
+				return
+			}
+
+			if e, ok := err.(*Error); !ok || e.Code != tc.code || e.Message != tc.message {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		err := keys.verifier(t).Verify(newDigestReq(digest), bytes.NewBufferString(body))
+		if err == nil {
+			t.Error("expected body to be signed by default")
+		}
+	})
+
+	t.Run("unsigned digest", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		req.Header.Set("Digest", "SHA-256=invalid")
+
+		if err := v.Verify(req, bytes.NewBufferString(body)); err != nil {
+			t.Error("expected unsigned digest to be ignored, got:", err)
+		}
+	})
+}
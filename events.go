@@ -0,0 +1,104 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+)
+
+// WebhookEvent is a signed request that has been captured, for example to a
+// database, so it may be verified later.
+type WebhookEvent struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Request reconstructs the captured request. The returned request's body reads
+// from a copy of the event body.
+func (e *WebhookEvent) Request() (*http.Request, error) {
+	return e.RequestWithContext(context.Background())
+}
+
+// RequestWithContext behaves like Request, returning a request with the
+// context ctx.
+func (e *WebhookEvent) RequestWithContext(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, e.Method, e.URL, bytes.NewReader(e.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vs := range e.Header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+
+	return req, nil
+}
+
+// Verify verifies the captured request with v.
+func (e *WebhookEvent) Verify(v *Verifier) error {
+	return e.VerifyContext(context.Background(), v)
+}
+
+// VerifyContext verifies the captured request with v, as Verifier's
+// VerifyContext does, abandoning verification once ctx is done.
+func (e *WebhookEvent) VerifyContext(ctx context.Context, v *Verifier) error {
+	req, err := e.RequestWithContext(ctx)
+	if err != nil {
+		return &Error{Code: 400, Message: "Could not reconstruct request", category: Malformed}
+	}
+
+	return v.VerifyContext(ctx, req, bytes.NewReader(e.Body))
+}
+
+// VerifyEvents verifies events concurrently using up to workers goroutines. The
+// returned slice holds the verification result for the event at the same
+// index.
+//
+// Each event is verified with ctx, as by VerifyContext. If ctx is done before
+// all events are verified, events whose verification was in progress are
+// abandoned with a Timeout Error, and the result of events that were not
+// started is the context's error.
+func VerifyEvents(ctx context.Context, v *Verifier, events []WebhookEvent, workers int) []error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	errs := make([]error, len(events))
+	idx := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = events[i].VerifyContext(ctx, v)
+			}
+		}()
+	}
+
+	i := 0
+loop:
+	for ; i < len(events) && ctx.Err() == nil; i++ {
+		select {
+		case idx <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(idx)
+	wg.Wait()
+
+	for ; i < len(events); i++ {
+		errs[i] = ctx.Err()
+	}
+
+	return errs
+}
@@ -0,0 +1,120 @@
+package signature
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func newTestEvent(t *testing.T, keys *testKeys, body string) WebhookEvent {
+	req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+	return WebhookEvent{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header,
+		Body:   []byte(body),
+	}
+}
+
+func TestVerifyEvents(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	var events []WebhookEvent
+	for i := 0; i < 10; i++ {
+		e := newTestEvent(t, keys, "body")
+		if i%3 == 0 {
+			e.Body = []byte("tampered")
+		}
+		events = append(events, e)
+	}
+
+	t.Run("mixed", func(t *testing.T) {
+		errs := VerifyEvents(context.Background(), v, events, 4)
+		if len(errs) != len(events) {
+			t.Fatal("unexpected number of results:", len(errs))
+		}
+
+		for i, err := range errs {
+			if i%3 == 0 && err == nil {
+				t.Errorf("expected event %d to fail verification", i)
+			}
+
+			if i%3 != 0 && err != nil {
+				t.Errorf("expected event %d to verify, got: %s", i, err)
+			}
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for i, err := range VerifyEvents(ctx, v, events, 2) {
+			if err != context.Canceled {
+				t.Errorf("expected event %d to be cancelled, got: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("cancelled mid-run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// The key source is consulted during validation, cancelling and
+		// then blocking while the first event is checked.
+		release := make(chan struct{})
+		defer close(release)
+		cv := keys.verifier(t, signedAt, WithKeySource(cancelSource(func() {
+			cancel()
+			<-release
+		})))
+		errs := VerifyEvents(ctx, cv, events[1:], 1)
+
+		if e, ok := errs[0].(*Error); !ok || e.Category() != Timeout {
+			t.Error("expected first event to be abandoned, got:", errs[0])
+		}
+
+		for i, err := range errs[1:] {
+			if err != context.Canceled {
+				t.Errorf("expected event %d not to be started, got: %v", i+1, err)
+			}
+		}
+	})
+
+	t.Run("request context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, err := events[1].RequestWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if req.Context() != ctx {
+			t.Error("expected request to have the given context")
+		}
+	})
+
+	t.Run("request body", func(t *testing.T) {
+		req, err := events[1].Request()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := ioutil.ReadAll(req.Body)
+		if string(b) != "body" {
+			t.Errorf("unexpected request body: %q", b)
+		}
+	})
+}
+
+// cancelSource is a KeySource that calls itself when consulted.
+type cancelSource func()
+
+func (c cancelSource) Keys() []ed25519.PublicKey {
+	c()
+	return nil
+}
@@ -0,0 +1,23 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestFingerprint returns a short, stable identifier for req, suitable for
+// correlating log entries. It is derived from the canonical request target and
+// the X-Signature header, and does not read the request body.
+//
+// Identical requests produce identical fingerprints. The fingerprint is not a
+// substitute for verification.
+func RequestFingerprint(req *http.Request) string {
+	var msg bytes.Buffer
+	(&canonOptions{}).writeTarget(&msg, req)
+	msg.WriteString(req.Header.Get("X-Signature"))
+
+	sum := sha256.Sum256(msg.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,48 @@
+package signature
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	fp := RequestFingerprint(newReq())
+	if len(fp) != 16 {
+		t.Errorf("unexpected fingerprint length: %q", fp)
+	}
+
+	if RequestFingerprint(newReq()) != fp {
+		t.Error("identical requests produced different fingerprints")
+	}
+
+	t.Run("query order", func(t *testing.T) {
+		a := newReq()
+		a.URL.RawQuery = "a=1&b=2"
+		b := newReq()
+		b.URL.RawQuery = "b=2&a=1"
+
+		if RequestFingerprint(a) != RequestFingerprint(b) {
+			t.Error("equivalent queries produced different fingerprints")
+		}
+	})
+
+	tcs := []struct {
+		name   string
+		mutate func(*http.Request)
+	}{
+		{"method", func(r *http.Request) { r.Method = "POST" }},
+		{"path", func(r *http.Request) { r.URL.Path = "/v1/other" }},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "a=1" }},
+		{"signature", func(r *http.Request) { r.Header.Set("X-Signature", "a b c") }},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newReq()
+			tc.mutate(req)
+			if RequestFingerprint(req) == fp {
+				t.Error("different requests produced the same fingerprint")
+			}
+		})
+	}
+}
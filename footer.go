@@ -0,0 +1,54 @@
+package signature
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// WithBodyFooterMarker configures the Verifier for self-contained signed
+// documents, whose signature is appended to the body after marker:
+//
+//	{"id":"2686c96868emyj61cgt2ma7vdntg4"}
+//	---SIGNATURE---
+//	<signature> <public key> <endorsement>
+//
+// The body is split at the last occurrence of marker. The content before it,
+// including any trailing newline, is used as the body in the canonical form,
+// and the footer after it, with surrounding whitespace removed, is used in
+// place of the X-Signature header. Requests without the marker are rejected,
+// and are not passed to a handler set by WithUnsignedHandler.
+func WithBodyFooterMarker(marker string) Option {
+	return func(v *Verifier) {
+		v.bodyFooterMarker = []byte(marker)
+	}
+}
+
+// unpackFooter reads body in full, returning a copy of req with its
+// X-Signature header set from the body's signature footer, and a reader of the
+// body preceding the footer.
+func (v *Verifier) unpackFooter(req *http.Request, body io.Reader) (*http.Request, io.Reader, error) {
+	b, err := ioutil.ReadAll(body)
+	if e, ok := err.(*Error); ok {
+		return nil, nil, e
+	}
+	if err != nil {
+		return nil, nil, &Error{Code: 400, Message: "Unable to read request body", category: Malformed}
+	}
+
+	i := bytes.LastIndex(b, v.bodyFooterMarker)
+	if i < 0 {
+		return nil, nil, &Error{Code: 400, Message: "Missing signature footer", category: Malformed, cause: ErrMissingSignature}
+	}
+
+	h := make(http.Header, len(req.Header)+1)
+	for k, vs := range req.Header {
+		h[k] = vs
+	}
+	h.Set("X-Signature", string(bytes.TrimSpace(b[i+len(v.bodyFooterMarker):])))
+
+	r := *req
+	r.Header = h
+	return &r, bytes.NewReader(b[:i]), nil
+}
@@ -0,0 +1,64 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithBodyFooterMarker(t *testing.T) {
+	keys := newTestKeys()
+	const marker = "---SIGNATURE---"
+	content := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}\n"
+
+	// newDocument returns a request with a signed document as its body, with
+	// the signature moved from the X-Signature header into a footer.
+	newDocument := func(content string) (*http.Request, string) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/documents", content)
+		doc := content + marker + "\n" + req.Header.Get("X-Signature") + "\n"
+		req.Header.Del("X-Signature")
+		return req, doc
+	}
+
+	v := keys.verifier(t, WithBodyFooterMarker(marker))
+
+	t.Run("inline signature", func(t *testing.T) {
+		req, doc := newDocument(content)
+		if err := v.Verify(req, bytes.NewBufferString(doc)); err != nil {
+			t.Error("expected document to verify, got:", err)
+		}
+	})
+
+	t.Run("marker in content", func(t *testing.T) {
+		req, doc := newDocument("quoting " + marker + "\n" + content)
+		if err := v.Verify(req, bytes.NewBufferString(doc)); err != nil {
+			t.Error("expected document to verify, got:", err)
+		}
+	})
+
+	t.Run("altered content", func(t *testing.T) {
+		req, doc := newDocument(content)
+		doc = strings.Replace(doc, "2686", "2687", 1)
+		if err := v.Verify(req, bytes.NewBufferString(doc)); err == nil {
+			t.Error("expected altered document to fail verification")
+		}
+	})
+
+	t.Run("footer takes precedence", func(t *testing.T) {
+		req, doc := newDocument(content)
+		req.Header.Set("X-Signature", "invalid")
+		if err := v.Verify(req, bytes.NewBufferString(doc)); err != nil {
+			t.Error("expected document to verify, got:", err)
+		}
+	})
+
+	t.Run("missing footer", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/documents", content)
+
+		err := v.Verify(req, bytes.NewBufferString(content))
+		if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != "Missing signature footer" {
+			t.Error("expected missing footer error, got:", err)
+		}
+	})
+}
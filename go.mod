@@ -1,8 +1,12 @@
 module github.com/manifoldco/go-signature
 
-go 1.12
+go 1.19
 
 require (
+	filippo.io/edwards25519 v1.0.0
+	github.com/cloudflare/circl v1.3.7
 	github.com/manifoldco/go-base64 v1.0.3
-	golang.org/x/crypto v0.0.0-20181112202954-3d3f9f413869
+	golang.org/x/crypto v0.17.0
 )
+
+require golang.org/x/sys v0.15.0 // indirect
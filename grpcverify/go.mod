@@ -0,0 +1,20 @@
+module github.com/manifoldco/go-signature/grpcverify
+
+go 1.24.0
+
+require (
+	github.com/manifoldco/go-base64 v1.0.3
+	github.com/manifoldco/go-signature v1.1.0
+	golang.org/x/crypto v0.46.0
+	google.golang.org/grpc v1.79.3
+	google.golang.org/protobuf v1.36.10
+)
+
+require (
+	filippo.io/edwards25519 v1.0.0 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
+	golang.org/x/net v0.48.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217 // indirect
+)
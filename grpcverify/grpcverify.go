@@ -0,0 +1,114 @@
+// Package grpcverify verifies signed gRPC calls, such as those forwarded by
+// grpc-gateway from signed HTTP requests.
+//
+// It is a separate module, only built with the "grpc" build tag, so that the
+// gRPC libraries and the Go version they require are not imposed on users of
+// the signature package.
+package grpcverify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/manifoldco/go-signature"
+)
+
+// Request returns the HTTP request that a signed call to the gRPC method
+// fullMethod, with the given incoming metadata, is canonicalized as.
+//
+// The request is a POST to the method's path, such as
+// "/grpc.health.v1.Health/Check", as sent by gRPC over HTTP/2. Its host is the
+// call's :authority, and its headers are the call's metadata, including the
+// X-Signature, X-Signed-Headers and Date values.
+func Request(fullMethod string, md metadata.MD) *http.Request {
+	header := http.Header{}
+	for k, vs := range md {
+		header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+	}
+
+	var host string
+	if a := md.Get(":authority"); len(a) > 0 {
+		host = a[0]
+	}
+
+	return &http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: fullMethod},
+		Host:   host,
+		Header: header,
+	}
+}
+
+// Marshal returns the body of a signed call with the request message msg. The
+// message is marshaled deterministically, so signers must sign the body
+// returned by Marshal, rather than the bytes sent on the wire.
+func Marshal(msg interface{}) ([]byte, error) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "Request message is not a protocol buffer")
+	}
+
+	return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
+
+// Verify verifies the signature of the call to fullMethod described by the
+// incoming metadata in ctx and the request message msg with v.
+//
+// The returned error is a gRPC status error. Malformed signatures are reported
+// with codes.InvalidArgument, and all other verification failures, including
+// a missing signature, with codes.Unauthenticated.
+func Verify(ctx context.Context, v *signature.Verifier, fullMethod string, msg interface{}) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "Missing X-Signature header")
+	}
+
+	b, err := Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if err := v.Verify(Request(fullMethod, md), bytes.NewReader(b)); err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that verifies the
+// signature of each unary call with v before passing it to its handler.
+// Calls that fail verification are rejected with the error returned by Verify.
+func UnaryServerInterceptor(v *signature.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if err := Verify(ctx, v, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// statusError returns err as a gRPC status error.
+func statusError(err error) error {
+	e, ok := err.(*signature.Error)
+	if !ok {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if e.Category() == signature.Malformed && !errors.Is(e, signature.ErrMissingSignature) {
+		return status.Error(codes.InvalidArgument, e.Message)
+	}
+
+	return status.Error(codes.Unauthenticated, e.Message)
+}
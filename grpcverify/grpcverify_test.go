@@ -0,0 +1,156 @@
+package grpcverify
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	base64url "github.com/manifoldco/go-base64"
+	"github.com/manifoldco/go-signature"
+)
+
+const method = "/grpc.health.v1.Health/Check"
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	master := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	live := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	livePub := live.Public().(ed25519.PublicKey)
+
+	v, err := signature.NewVerifier(base64url.New(master.Public().(ed25519.PublicKey)).String(),
+		signature.WithSkewByPath(map[string]time.Duration{"/": 100 * 365 * 24 * time.Hour}))
+	if err != nil {
+		t.Fatal("could not create verifier:", err)
+	}
+
+	s := signature.NewSigner(live, base64url.New(ed25519.Sign(master, livePub)))
+
+	msg := &healthpb.HealthCheckRequest{Service: "resources"}
+
+	// signedMD returns the metadata of a call to method with msg, signed by s.
+	signedMD := func(t *testing.T) metadata.MD {
+		md := metadata.Pairs(
+			":authority", "127.0.0.1:4567",
+			"date", "2017-03-05T23:53:08Z",
+			"x-signed-headers", "host date",
+		)
+
+		b, err := Marshal(msg)
+		if err != nil {
+			t.Fatal("could not marshal message:", err)
+		}
+
+		req := Request(method, md)
+		if err := s.Sign(req, bytes.NewReader(b)); err != nil {
+			t.Fatal("could not sign call:", err)
+		}
+
+		md.Set("x-signature", req.Header.Get("X-Signature"))
+		return md
+	}
+
+	intercept := func(ctx context.Context, req interface{}) (bool, error) {
+		called := false
+		_, err := UnaryServerInterceptor(v)(ctx, req, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return &healthpb.HealthCheckResponse{}, nil
+			})
+		return called, err
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), signedMD(t))
+		called, err := intercept(ctx, msg)
+		if err != nil || !called {
+			t.Error("expected call to verify, got:", err)
+		}
+	})
+
+	tcs := []struct {
+		name string
+		ctx  func(t *testing.T) context.Context
+		req  interface{}
+		code codes.Code
+	}{
+		{
+			"no metadata",
+			func(t *testing.T) context.Context { return context.Background() },
+			msg, codes.Unauthenticated,
+		},
+		{
+			"missing signature",
+			func(t *testing.T) context.Context {
+				md := signedMD(t)
+				delete(md, "x-signature")
+				return metadata.NewIncomingContext(context.Background(), md)
+			},
+			msg, codes.Unauthenticated,
+		},
+		{
+			"malformed signature",
+			func(t *testing.T) context.Context {
+				md := signedMD(t)
+				md.Set("x-signature", "not a signature")
+				return metadata.NewIncomingContext(context.Background(), md)
+			},
+			msg, codes.InvalidArgument,
+		},
+		{
+			"tampered message",
+			func(t *testing.T) context.Context {
+				return metadata.NewIncomingContext(context.Background(), signedMD(t))
+			},
+			&healthpb.HealthCheckRequest{Service: "other"}, codes.Unauthenticated,
+		},
+		{
+			"tampered metadata",
+			func(t *testing.T) context.Context {
+				md := signedMD(t)
+				md.Set(":authority", "example.com")
+				return metadata.NewIncomingContext(context.Background(), md)
+			},
+			msg, codes.Unauthenticated,
+		},
+		{
+			"not a protocol buffer",
+			func(t *testing.T) context.Context {
+				return metadata.NewIncomingContext(context.Background(), signedMD(t))
+			},
+			"resources", codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			called, err := intercept(tc.ctx(t), tc.req)
+			if called {
+				t.Error("expected handler not to be called")
+			}
+			if c := status.Code(err); c != tc.code {
+				t.Errorf("expected code %s, got %s: %v", tc.code, c, err)
+			}
+		})
+	}
+}
+
+func TestRequest(t *testing.T) {
+	md := metadata.Pairs(":authority", "example.com", "x-multi", "a", "x-multi", "b")
+	req := Request(method, md)
+
+	if req.Method != "POST" || req.URL.Path != method || req.Host != "example.com" {
+		t.Errorf("unexpected request: %s %s %s", req.Method, req.Host, req.URL.Path)
+	}
+
+	if got := strings.Join(req.Header["X-Multi"], ","); got != "a,b" {
+		t.Error("unexpected multi value header:", got)
+	}
+}
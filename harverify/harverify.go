@@ -0,0 +1,79 @@
+// Package harverify verifies signed requests captured in HAR (HTTP Archive)
+// format, such as those exported by browsers and debugging proxies.
+package harverify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/manifoldco/go-signature"
+)
+
+// HAR is the subset of an HTTP Archive needed to verify its requests.
+type HAR struct {
+	Log struct {
+		Entries []Entry `json:"entries"`
+	} `json:"log"`
+}
+
+// Entry is a single request and response pair in a HAR.
+type Entry struct {
+	Request Request `json:"request"`
+}
+
+// Request is a captured request.
+type Request struct {
+	Method   string    `json:"method"`
+	URL      string    `json:"url"`
+	Headers  []Header  `json:"headers"`
+	PostData *PostData `json:"postData,omitempty"`
+}
+
+// Header is a single captured header value.
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is the captured body of a request.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Parse reads a HAR from r.
+func Parse(r io.Reader) (*HAR, error) {
+	h := &HAR{}
+	if err := json.NewDecoder(r).Decode(h); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Event returns the captured request as a signature.WebhookEvent.
+func (e *Entry) Event() signature.WebhookEvent {
+	header := http.Header{}
+	for _, h := range e.Request.Headers {
+		header.Add(h.Name, h.Value)
+	}
+
+	var body []byte
+	if e.Request.PostData != nil {
+		body = []byte(e.Request.PostData.Text)
+	}
+
+	return signature.WebhookEvent{
+		Method: e.Request.Method,
+		URL:    e.Request.URL,
+		Header: header,
+		Body:   body,
+	}
+}
+
+// Verify verifies the signature of the entry's request with v.
+func Verify(v *signature.Verifier, e *Entry) error {
+	ev := e.Event()
+	return ev.Verify(v)
+}
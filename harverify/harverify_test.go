@@ -0,0 +1,81 @@
+package harverify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manifoldco/go-signature"
+)
+
+const sample = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "startedDateTime": "2017-03-05T23:53:08.000Z",
+        "request": {
+          "method": "PUT",
+          "url": "https://127.0.0.1:4567/v1/resources/2686c96868emyj61cgt2ma7vdntg4",
+          "httpVersion": "HTTP/1.1",
+          "headers": [
+            {"name": "Host", "value": "127.0.0.1:4567"},
+            {"name": "Date", "value": "2017-03-05T23:53:08Z"},
+            {"name": "Content-Length", "value": "143"},
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "X-Signed-Headers", "value": "host date content-type content-length"},
+            {"name": "X-Signature", "value": "Nb9iJZVDFrcf8-dw7AsuSCPtdoxoAr61YVWQe-5b9z_YiuQW73wR7RRsDBPnrBMtXIg_h8yKWsr-ZNRgYbM7CA FzNbTkRjAGjkpwHUbAhjvLsIlAlL_M6EUh5E9OVEwXs qGR6iozBfLUCHbRywz1mHDdGYeqZ0JEcseV4KcwjEVeZtQN54odcJ1_QyZkmHacbQeHEai2-Aw9EF8-Ceh09Cg"}
+          ],
+          "postData": {
+            "mimeType": "application/json",
+            "text": "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\",\"plan\":\"low\",\"product\":\"generators\",\"region\":\"aws::us-east-1\",\"user_id\":\"200e7aeg2kf2d6nud8jran3zxnz5j\"}\n"
+          }
+        }
+      },
+      {
+        "startedDateTime": "2017-03-05T23:53:08.000Z",
+        "request": {
+          "method": "PUT",
+          "url": "https://127.0.0.1:4567/v1/resources/2686c96868emyj61cgt2ma7vdntg4",
+          "httpVersion": "HTTP/1.1",
+          "headers": [
+            {"name": "Host", "value": "127.0.0.1:4567"},
+            {"name": "Date", "value": "2017-03-05T23:53:08Z"},
+            {"name": "Content-Length", "value": "143"},
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "X-Signed-Headers", "value": "host date content-type content-length"},
+            {"name": "X-Signature", "value": "Nb9iJZVDFrcf8-dw7AsuSCPtdoxoAr61YVWQe-5b9z_YiuQW73wR7RRsDBPnrBMtXIg_h8yKWsr-ZNRgYbM7CA FzNbTkRjAGjkpwHUbAhjvLsIlAlL_M6EUh5E9OVEwXs qGR6iozBfLUCHbRywz1mHDdGYeqZ0JEcseV4KcwjEVeZtQN54odcJ1_QyZkmHacbQeHEai2-Aw9EF8-Ceh09Cg"}
+          ],
+          "postData": {
+            "mimeType": "application/json",
+            "text": "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\",\"plan\":\"high\",\"product\":\"generators\",\"region\":\"aws::us-east-1\",\"user_id\":\"200e7aeg2kf2d6nud8jran3zxnz5j\"}\n"
+          }
+        }
+      }
+    ]
+  }
+}`
+
+func TestVerify(t *testing.T) {
+	h, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal("could not parse HAR:", err)
+	}
+
+	if len(h.Log.Entries) != 2 {
+		t.Fatal("unexpected number of entries:", len(h.Log.Entries))
+	}
+
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+	v, _ := signature.NewVerifier(dummyKey, signature.WithSkewByPath(map[string]time.Duration{
+		"/": 100 * 365 * 24 * time.Hour,
+	}))
+
+	if err := Verify(v, &h.Log.Entries[0]); err != nil {
+		t.Error("expected entry to verify, got:", err)
+	}
+
+	if err := Verify(v, &h.Log.Entries[1]); err == nil {
+		t.Error("expected tampered entry to fail verification")
+	}
+}
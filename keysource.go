@@ -0,0 +1,181 @@
+package signature
+
+import (
+	stded25519 "crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// KeySource provides additional master public keys that a Verifier trusts to
+// endorse request keys.
+type KeySource interface {
+	Keys() []ed25519.PublicKey
+}
+
+// WithKeySource configures the Verifier to also trust the master keys provided
+// by ks, alongside the key given to NewVerifier. The source is consulted on
+// every verification, so it may change the key set over time.
+func WithKeySource(ks KeySource) Option {
+	return func(v *Verifier) {
+		v.keys = ks
+	}
+}
+
+// DirKeyPollInterval is how often a DirKeys source checks its directory for
+// changes.
+const DirKeyPollInterval = 30 * time.Second
+
+// DirKeys is a KeySource backed by a directory of key files. Each file ending
+// in ".pub" holds a base64 encoded public key, in the format accepted by
+// NewVerifier. Each file ending in ".pem" holds a PEM encoded PKIX Ed25519
+// public key.
+//
+// The directory is polled for changes, so keys may be rotated by adding and
+// removing files.
+type DirKeys struct {
+	dir string
+
+	mu   sync.RWMutex
+	keys []ed25519.PublicKey
+
+	done chan struct{}
+	once sync.Once
+}
+
+// DirKeySource returns a DirKeys source that loads its keys from dir. It
+// returns an error if the initial load fails.
+//
+// The returned source polls dir every DirKeyPollInterval until it is closed.
+func DirKeySource(dir string) (*DirKeys, error) {
+	d := &DirKeys{dir: dir, done: make(chan struct{})}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+
+	t := time.NewTicker(DirKeyPollInterval)
+	go func() {
+		defer t.Stop()
+		d.poll(t.C)
+	}()
+	return d, nil
+}
+
+// Keys returns the keys most recently loaded from the directory.
+func (d *DirKeys) Keys() []ed25519.PublicKey {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.keys
+}
+
+// Reload reads all key files from the directory, replacing the active key set.
+// If any file can not be read, the active key set is left unchanged.
+func (d *DirKeys) Reload() error {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+
+	var keys []ed25519.PublicKey
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		name := filepath.Join(d.dir, f.Name())
+		var key ed25519.PublicKey
+		switch filepath.Ext(name) {
+		case ".pub":
+			key, err = readPubKeyFile(name)
+		case ".pem":
+			key, err = readPEMKeyFile(name)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	d.mu.Lock()
+	d.keys = keys
+	d.mu.Unlock()
+	return nil
+}
+
+// Close stops polling the directory. The most recently loaded keys remain
+// available.
+func (d *DirKeys) Close() {
+	d.once.Do(func() { close(d.done) })
+}
+
+// poll reloads the directory on each tick, until the source is closed.
+func (d *DirKeys) poll(tick <-chan time.Time) {
+	for {
+		select {
+		case <-tick:
+			// Errors are transient (e.g. a partially written file); the
+			// previous key set is kept until the next successful load.
+			d.Reload() // nolint: errcheck
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func readPubKeyFile(name string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := parsePublicKey(Ed25519, strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	return ed25519.PublicKey(pk.key), nil
+}
+
+func readPEMKeyFile(name string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+
+	pk, err := x509ParseEd25519(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return ed25519.PublicKey(pk), nil
+}
+
+func x509ParseEd25519(der []byte) (stded25519.PublicKey, error) {
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, ok := pub.(stded25519.PublicKey)
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return pk, nil
+}
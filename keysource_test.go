@@ -0,0 +1,200 @@
+package signature
+
+import (
+	"bytes"
+	stded25519 "crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestDirKeySource(t *testing.T) {
+	keys := newTestKeys()
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+
+	dir, err := ioutil.TempDir("", "signature-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := DirKeySource(dir)
+	if err != nil {
+		t.Fatal("could not create key source:", err)
+	}
+	defer src.Close()
+
+	verifier, _ := NewVerifier(dummyKey, WithKeySource(src))
+
+	verify := func() error {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		return verifier.Verify(req, bytes.NewBufferString("body"))
+	}
+
+	if err := verify(); err == nil {
+		t.Fatal("expected request to fail before its key was added")
+	}
+
+	t.Run("pub file", func(t *testing.T) {
+		name := filepath.Join(dir, "master.pub")
+		if err := ioutil.WriteFile(name, []byte(keys.masterKey()+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+
+		if err := src.Reload(); err != nil {
+			t.Fatal("could not reload keys:", err)
+		}
+
+		if err := verify(); err != nil {
+			t.Error("expected request to verify, got:", err)
+		}
+	})
+
+	t.Run("pem file", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(stded25519.PublicKey(keys.master.Public().(ed25519.PublicKey)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name := filepath.Join(dir, "master.pem")
+		b := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		if err := ioutil.WriteFile(name, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+
+		if err := src.Reload(); err != nil {
+			t.Fatal("could not reload keys:", err)
+		}
+
+		if err := verify(); err != nil {
+			t.Error("expected request to verify, got:", err)
+		}
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		if err := src.Reload(); err != nil {
+			t.Fatal("could not reload keys:", err)
+		}
+
+		if err := verify(); err == nil {
+			t.Error("expected request to fail after its key was removed")
+		}
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		name := filepath.Join(dir, "bad.pub")
+		if err := ioutil.WriteFile(name, []byte("nope"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+
+		if err := src.Reload(); err == nil {
+			t.Error("expected an invalid key file to error")
+		}
+	})
+}
+
+func TestDirKeySourcePolling(t *testing.T) {
+	keys := newTestKeys()
+
+	dir, err := ioutil.TempDir("", "signature-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &DirKeys{dir: dir, done: make(chan struct{})}
+	if err := src.Reload(); err != nil {
+		t.Fatal("could not load keys:", err)
+	}
+	defer src.Close()
+
+	tick := make(chan time.Time)
+	go src.poll(tick)
+
+	v, _ := NewVerifier("PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk", WithKeySource(src))
+	verify := func() error {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		return v.Verify(req, bytes.NewBufferString("body"))
+	}
+
+	name := filepath.Join(dir, "master.pub")
+	if err := ioutil.WriteFile(name, []byte(keys.masterKey()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verify(); err == nil {
+		t.Fatal("expected request to fail before the directory was polled")
+	}
+
+	// The second tick is only received once the first reload is done.
+	tick <- time.Now()
+	tick <- time.Now()
+
+	if err := verify(); err != nil {
+		t.Error("expected polled key to verify, got:", err)
+	}
+}
+
+// intervalSource is a KeySource that, like a polled source, provides its
+// pending keys once interval has passed since loaded, by the Verifier's clock.
+type intervalSource struct {
+	loaded   time.Time
+	interval time.Duration
+	pending  []ed25519.PublicKey
+}
+
+func (s *intervalSource) Keys() []ed25519.PublicKey {
+	return s.keysAt(time.Now())
+}
+
+func (s *intervalSource) keysAt(now time.Time) []ed25519.PublicKey {
+	if now.Sub(s.loaded) < s.interval {
+		return nil
+	}
+	return s.pending
+}
+
+func TestKeySourceRefresh(t *testing.T) {
+	keys := newTestKeys()
+
+	loaded := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	now := loaded
+	src := &intervalSource{
+		loaded:   loaded,
+		interval: DirKeyPollInterval,
+		pending:  []ed25519.PublicKey{keys.master.Public().(ed25519.PublicKey)},
+	}
+
+	// The key given to NewVerifier is unrelated to the source.
+	unrelated := newTestKeys()
+	unrelated.master = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{5}, ed25519.SeedSize))
+	verifier := unrelated.verifier(t, WithKeySource(src), WithClock(func() time.Time { return now }))
+
+	verify := func() error {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		return verifier.Verify(req, bytes.NewBufferString("body"))
+	}
+
+	now = loaded.Add(DirKeyPollInterval - time.Second)
+	if err := verify(); err == nil {
+		t.Error("expected request to fail before the interval passed")
+	}
+
+	now = loaded.Add(DirKeyPollInterval)
+	if err := verify(); err != nil {
+		t.Error("expected key to be picked up after the interval, got:", err)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/manifoldco/go-signature/lambdaverify
+
+go 1.19
+
+require (
+	github.com/aws/aws-lambda-go v1.47.0
+	github.com/manifoldco/go-base64 v1.0.3
+	github.com/manifoldco/go-signature v1.1.0
+	golang.org/x/crypto v0.17.0
+)
+
+require (
+	filippo.io/edwards25519 v1.0.0 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
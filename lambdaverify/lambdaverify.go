@@ -0,0 +1,129 @@
+// Package lambdaverify verifies signed requests delivered to AWS Lambda
+// functions through an API Gateway proxy integration, or an Application Load
+// Balancer target group.
+//
+// It is a separate module, so that the AWS Lambda libraries are not required
+// by other users of go-signature.
+package lambdaverify
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/manifoldco/go-signature"
+)
+
+// Event returns the request described by the proxy event as a
+// signature.WebhookEvent.
+//
+// The query string is rebuilt from the event's parsed parameters, as API
+// Gateway does not provide the original. Signed requests should encode their
+// query parameters in the form produced by url.Values.Encode.
+func Event(event events.APIGatewayProxyRequest) (signature.WebhookEvent, error) {
+	query := url.Values{}
+	for k, v := range event.QueryStringParameters {
+		if _, ok := event.MultiValueQueryStringParameters[k]; !ok {
+			query.Add(k, v)
+		}
+	}
+	for k, vs := range event.MultiValueQueryStringParameters {
+		for _, v := range vs {
+			query.Add(k, v)
+		}
+	}
+
+	return newEvent(event.HTTPMethod, event.Path, query.Encode(), event.Headers,
+		event.MultiValueHeaders, event.Body, event.IsBase64Encoded)
+}
+
+// ALBEvent returns the request described by the load balancer event as a
+// signature.WebhookEvent.
+//
+// The load balancer passes query parameters without decoding them, so the
+// query string is rebuilt from them as sent.
+func ALBEvent(event events.ALBTargetGroupRequest) (signature.WebhookEvent, error) {
+	var query []string
+	for k, v := range event.QueryStringParameters {
+		if _, ok := event.MultiValueQueryStringParameters[k]; !ok {
+			query = append(query, k+"="+v)
+		}
+	}
+	for k, vs := range event.MultiValueQueryStringParameters {
+		for _, v := range vs {
+			query = append(query, k+"="+v)
+		}
+	}
+	sort.Strings(query)
+
+	return newEvent(event.HTTPMethod, event.Path, strings.Join(query, "&"), event.Headers,
+		event.MultiValueHeaders, event.Body, event.IsBase64Encoded)
+}
+
+// newEvent returns a signature.WebhookEvent for the given request fields, as
+// provided by Lambda events. Binary bodies are base64 encoded by Lambda, and
+// are decoded if isBase64Encoded is set, so that the signature is checked
+// against the raw bytes.
+func newEvent(method, path, rawQuery string, headers map[string]string, multiValueHeaders map[string][]string,
+	body string, isBase64Encoded bool) (signature.WebhookEvent, error) {
+
+	header := http.Header{}
+	for k, v := range headers {
+		if _, ok := multiValueHeaders[k]; !ok {
+			header.Add(k, v)
+		}
+	}
+	for k, vs := range multiValueHeaders {
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "https",
+		Host:     header.Get("Host"),
+		Path:     path,
+		RawQuery: rawQuery,
+	}
+
+	b := []byte(body)
+	if isBase64Encoded {
+		var err error
+		if b, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return signature.WebhookEvent{}, err
+		}
+	}
+
+	return signature.WebhookEvent{
+		Method: method,
+		URL:    u.String(),
+		Header: header,
+		Body:   b,
+	}, nil
+}
+
+// VerifyLambdaProxy verifies the signature of the request described by the
+// proxy event with v.
+func VerifyLambdaProxy(event events.APIGatewayProxyRequest, v *signature.Verifier) error {
+	ev, err := Event(event)
+	if err != nil {
+		return &signature.Error{Code: 400, Message: "Could not decode request body"}
+	}
+
+	return ev.Verify(v)
+}
+
+// VerifyALB verifies the signature of the request described by the load
+// balancer event with v.
+func VerifyALB(event events.ALBTargetGroupRequest, v *signature.Verifier) error {
+	ev, err := ALBEvent(event)
+	if err != nil {
+		return &signature.Error{Code: 400, Message: "Could not decode request body"}
+	}
+
+	return ev.Verify(v)
+}
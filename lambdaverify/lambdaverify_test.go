@@ -0,0 +1,200 @@
+package lambdaverify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"golang.org/x/crypto/ed25519"
+
+	base64url "github.com/manifoldco/go-base64"
+	"github.com/manifoldco/go-signature"
+)
+
+const sample = `{
+  "resource": "/v1/resources/{id}",
+  "path": "/v1/resources/2686c96868emyj61cgt2ma7vdntg4",
+  "httpMethod": "PUT",
+  "headers": {
+    "Host": "127.0.0.1:4567",
+    "Date": "2017-03-05T23:53:08Z",
+    "Content-Length": "143",
+    "Content-Type": "application/json",
+    "X-Signed-Headers": "host date content-type content-length",
+    "X-Signature": "Nb9iJZVDFrcf8-dw7AsuSCPtdoxoAr61YVWQe-5b9z_YiuQW73wR7RRsDBPnrBMtXIg_h8yKWsr-ZNRgYbM7CA FzNbTkRjAGjkpwHUbAhjvLsIlAlL_M6EUh5E9OVEwXs qGR6iozBfLUCHbRywz1mHDdGYeqZ0JEcseV4KcwjEVeZtQN54odcJ1_QyZkmHacbQeHEai2-Aw9EF8-Ceh09Cg"
+  },
+  "pathParameters": {"id": "2686c96868emyj61cgt2ma7vdntg4"},
+  "requestContext": {"stage": "prod"},
+  "body": "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\",\"plan\":\"low\",\"product\":\"generators\",\"region\":\"aws::us-east-1\",\"user_id\":\"200e7aeg2kf2d6nud8jran3zxnz5j\"}\n",
+  "isBase64Encoded": false
+}`
+
+func sampleEvent(t *testing.T) events.APIGatewayProxyRequest {
+	var event events.APIGatewayProxyRequest
+	if err := json.Unmarshal([]byte(sample), &event); err != nil {
+		t.Fatal("could not parse proxy event:", err)
+	}
+
+	return event
+}
+
+func TestVerifyLambdaProxy(t *testing.T) {
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+	v, _ := signature.NewVerifier(dummyKey, signature.WithSkewByPath(map[string]time.Duration{
+		"/": 100 * 365 * 24 * time.Hour,
+	}))
+
+	t.Run("valid", func(t *testing.T) {
+		if err := VerifyLambdaProxy(sampleEvent(t), v); err != nil {
+			t.Error("expected event to verify, got:", err)
+		}
+	})
+
+	t.Run("base64 body", func(t *testing.T) {
+		event := sampleEvent(t)
+		event.Body = base64.StdEncoding.EncodeToString([]byte(event.Body))
+		event.IsBase64Encoded = true
+
+		if err := VerifyLambdaProxy(event, v); err != nil {
+			t.Error("expected event to verify, got:", err)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		event := sampleEvent(t)
+		event.Body = event.Body[:len(event.Body)-1]
+
+		if err := VerifyLambdaProxy(event, v); err == nil {
+			t.Error("expected tampered event to fail verification")
+		}
+	})
+
+	t.Run("invalid base64 body", func(t *testing.T) {
+		event := sampleEvent(t)
+		event.IsBase64Encoded = true
+
+		err := VerifyLambdaProxy(event, v)
+		if e, ok := err.(*signature.Error); !ok || e.Code != 400 {
+			t.Error("expected malformed body error, got:", err)
+		}
+	})
+}
+
+func TestEvent(t *testing.T) {
+	event := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/v1/resources",
+		Headers:    map[string]string{"host": "example.com", "x-single": "a"},
+		MultiValueHeaders: map[string][]string{
+			"x-single": {"a"},
+			"x-multi":  {"b", "c"},
+		},
+		QueryStringParameters: map[string]string{"page": "2", "tag": "z"},
+		MultiValueQueryStringParameters: map[string][]string{
+			"tag": {"x", "y"},
+		},
+	}
+
+	ev, err := Event(event)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if ev.URL != "https://example.com/v1/resources?page=2&tag=x&tag=y" {
+		t.Error("unexpected url:", ev.URL)
+	}
+
+	if got := ev.Header["X-Single"]; len(got) != 1 || got[0] != "a" {
+		t.Error("unexpected single value header:", got)
+	}
+
+	if got := ev.Header["X-Multi"]; len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Error("unexpected multi value header:", got)
+	}
+}
+
+func TestVerifyALB(t *testing.T) {
+	master := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	live := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	livePub := live.Public().(ed25519.PublicKey)
+
+	v, err := signature.NewVerifier(base64url.New(master.Public().(ed25519.PublicKey)).String(),
+		signature.WithSkewByPath(map[string]time.Duration{"/": 100 * 365 * 24 * time.Hour}))
+	if err != nil {
+		t.Fatal("could not create verifier:", err)
+	}
+
+	// A binary body, which is not valid UTF-8.
+	body := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0xff}
+
+	req, _ := http.NewRequest("POST", "https://example.com/v1/images?name=a%20b&tag=x", bytes.NewReader(body))
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("Content-Type", "image/png")
+	req.Header.Set("X-Signed-Headers", "host date content-type")
+
+	b, _ := signature.Canonize(req, bytes.NewReader(body))
+	sig := &signature.Signature{
+		Value:       base64url.New(ed25519.Sign(live, b)),
+		PublicKey:   base64url.New(livePub),
+		Endorsement: base64url.New(ed25519.Sign(master, livePub)),
+	}
+
+	newEvent := func() events.ALBTargetGroupRequest {
+		return events.ALBTargetGroupRequest{
+			HTTPMethod:            "POST",
+			Path:                  "/v1/images",
+			QueryStringParameters: map[string]string{"name": "a%20b", "tag": "x"},
+			Headers: map[string]string{
+				"host":             "example.com",
+				"date":             "2017-03-05T23:53:08Z",
+				"content-type":     "image/png",
+				"x-signed-headers": "host date content-type",
+				"x-signature":      sig.String(),
+			},
+			Body:            base64.StdEncoding.EncodeToString(body),
+			IsBase64Encoded: true,
+		}
+	}
+
+	t.Run("base64 binary body", func(t *testing.T) {
+		if err := VerifyALB(newEvent(), v); err != nil {
+			t.Error("expected event to verify, got:", err)
+		}
+	})
+
+	t.Run("encoded body not decoded", func(t *testing.T) {
+		event := newEvent()
+		event.IsBase64Encoded = false
+
+		if err := VerifyALB(event, v); err == nil {
+			t.Error("expected encoded body to fail verification")
+		}
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		event := newEvent()
+		event.Body = base64.StdEncoding.EncodeToString(append(body, 0))
+
+		if err := VerifyALB(event, v); err == nil {
+			t.Error("expected tampered body to fail verification")
+		}
+	})
+
+	t.Run("multi value", func(t *testing.T) {
+		event := newEvent()
+		event.MultiValueQueryStringParameters = map[string][]string{"name": {"a%20b"}, "tag": {"x"}}
+		event.MultiValueHeaders = map[string][]string{}
+		for k, v := range event.Headers {
+			event.MultiValueHeaders[k] = []string{v}
+		}
+		event.QueryStringParameters, event.Headers = nil, nil
+
+		if err := VerifyALB(event, v); err != nil {
+			t.Error("expected event to verify, got:", err)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package signature
+
+import (
+	"io"
+)
+
+// WithMaxBodySize configures the Verifier to reject requests whose bodies are
+// larger than n bytes with a 413 Error. At most n+1 bytes of the body are read,
+// so oversized bodies are rejected before they are buffered in full by the
+// middleware, or canonicalized by Verify.
+func WithMaxBodySize(n int64) Option {
+	return func(v *Verifier) {
+		v.maxBodySize = n
+	}
+}
+
+// errBodyTooLarge returns the Error for a request body larger than the
+// Verifier's maximum body size.
+func errBodyTooLarge() *Error {
+	return &Error{Code: 413, Message: "Request body is too large", category: TooLarge}
+}
+
+// limitBody returns a reader yielding at most n bytes of body, followed by an
+// error if body holds more.
+func limitBody(body io.Reader, n int64) io.Reader {
+	return io.MultiReader(io.LimitReader(body, n), &overflowReader{r: body})
+}
+
+// overflowReader reads from r once the body limit has been reached, returning
+// an error if there is anything more to read.
+type overflowReader struct {
+	r io.Reader
+}
+
+func (o *overflowReader) Read(p []byte) (int, error) {
+	var b [1]byte
+	n, err := io.ReadFull(o.r, b[:])
+	if n > 0 {
+		return 0, errBodyTooLarge()
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return 0, err
+}
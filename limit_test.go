@@ -0,0 +1,91 @@
+package signature
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithMaxBodySize(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithMaxBodySize(8))
+
+	tcs := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"empty", "", http.StatusOK},
+		{"at limit", "12345678", http.StatusOK},
+		{"over limit", "123456789", http.StatusRequestEntityTooLarge},
+		{"far over limit", strings.Repeat("x", 1<<20), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name+" verify", func(t *testing.T) {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", tc.body)
+
+			err := v.Verify(req, bytes.NewBufferString(tc.body))
+			if tc.code == http.StatusOK && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if tc.code != http.StatusOK {
+				if e, ok := err.(*Error); !ok || e.Code != tc.code {
+					t.Errorf("expected a %d Error, got: %v", tc.code, err)
+				}
+			}
+		})
+
+		t.Run(tc.name+" middleware", func(t *testing.T) {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", tc.body)
+
+			var got string
+			rw := httptest.NewRecorder()
+			v.WrapFunc(func(_ http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				got = string(b)
+			}).ServeHTTP(rw, req)
+
+			if rw.Code != tc.code {
+				t.Errorf("expected response code %d, got %d", tc.code, rw.Code)
+			}
+
+			if tc.code == http.StatusOK && got != tc.body {
+				t.Errorf("expected handler to read body %q, got %q", tc.body, got)
+			}
+		})
+	}
+
+	t.Run("unread remainder", func(t *testing.T) {
+		body := &countingReader{r: strings.NewReader(strings.Repeat("x", 1<<20))}
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "")
+		req.Body = ioutil.NopCloser(body)
+
+		rw := httptest.NewRecorder()
+		v.WrapFunc(func(http.ResponseWriter, *http.Request) {}).ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusRequestEntityTooLarge {
+			t.Error("unexpected response code:", rw.Code)
+		}
+
+		if body.n > 1024 {
+			t.Errorf("expected little of the body to be read, read %d bytes", body.n)
+		}
+	})
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r *strings.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
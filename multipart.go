@@ -0,0 +1,53 @@
+package signature
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+)
+
+// CanonicalMultipartBoundary is the boundary used in the canonical form of
+// multipart requests by a Verifier configured with
+// WithMultipartBoundaryNormalization.
+const CanonicalMultipartBoundary = "signature-canonical-boundary"
+
+// WithMultipartBoundaryNormalization configures the Verifier to replace the
+// boundary of multipart request bodies with CanonicalMultipartBoundary before
+// canonicalizing them, so that a body re-encoded by an intermediary with a new
+// boundary still verifies. The boundary parameter of a signed Content-Type
+// header is replaced in the same way. The signer must sign the normalized
+// form.
+func WithMultipartBoundaryNormalization() Option {
+	return func(v *Verifier) {
+		v.canon.multipartBoundary = true
+	}
+}
+
+// multipartBoundary returns the boundary of the multipart Content-Type value
+// contentType, or an empty string if it is not multipart.
+func multipartBoundary(contentType string) string {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		return ""
+	}
+
+	return params["boundary"]
+}
+
+// canonicalContentType returns contentType with the boundary of a multipart
+// media type replaced by CanonicalMultipartBoundary.
+func canonicalContentType(contentType string) string {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mt, "multipart/") || params["boundary"] == "" {
+		return contentType
+	}
+
+	params["boundary"] = CanonicalMultipartBoundary
+	return mime.FormatMediaType(mt, params)
+}
+
+// normalizeBoundary returns body with each delimiter using boundary replaced
+// by one using CanonicalMultipartBoundary.
+func normalizeBoundary(body []byte, boundary string) []byte {
+	return bytes.Replace(body, []byte("--"+boundary), []byte("--"+CanonicalMultipartBoundary), -1)
+}
@@ -0,0 +1,91 @@
+package signature
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+func TestWithMultipartBoundaryNormalization(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{multipartBoundary: true}
+
+	// newMultipart returns a multipart body and its Content-Type, using the
+	// given boundary.
+	newMultipart := func(boundary string) ([]byte, string) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		mw.SetBoundary(boundary)
+		mw.WriteField("name", "generators")
+		fw, _ := mw.CreateFormFile("config", "config.json")
+		fw.Write([]byte("{\"plan\":\"low\"}"))
+		mw.Close()
+		return b.Bytes(), mw.FormDataContentType()
+	}
+
+	// The signer signs the normalized form of a body with its own boundary.
+	signed, signedType := newMultipart("signer-boundary")
+	req, _ := http.NewRequest("POST", "https://127.0.0.1:4567/v1/resources", nil)
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("Content-Type", signedType)
+	req.Header.Set("X-Signed-Headers", "host date content-type")
+
+	b, err := canon.canonize(req, bytes.NewReader(signed))
+	if err != nil {
+		t.Fatal("could not canonize request:", err)
+	}
+	if bytes.Contains(b, []byte("signer-boundary")) {
+		t.Error("expected boundary to be normalized in canonical form")
+	}
+	keys.sign(req, b)
+
+	v := keys.verifier(t, WithMultipartBoundaryNormalization())
+
+	t.Run("same boundary", func(t *testing.T) {
+		if err := v.Verify(req, bytes.NewReader(signed)); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("regenerated boundary", func(t *testing.T) {
+		body, contentType := newMultipart("intermediary-boundary")
+		req.Header.Set("Content-Type", contentType)
+		defer req.Header.Set("Content-Type", signedType)
+
+		if err := v.Verify(req, bytes.NewReader(body)); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, bytes.NewReader(body)); err == nil {
+			t.Error("expected regenerated boundary to fail verification by default")
+		}
+	})
+
+	t.Run("altered part", func(t *testing.T) {
+		body := bytes.Replace(signed, []byte("low"), []byte("high"), 1)
+		if err := v.Verify(req, bytes.NewReader(body)); err == nil {
+			t.Error("expected altered part to fail verification")
+		}
+	})
+}
+
+func TestCanonicalContentType(t *testing.T) {
+	tcs := []struct {
+		in       string
+		expected string
+	}{
+		{"multipart/form-data; boundary=abc", "multipart/form-data; boundary=" + CanonicalMultipartBoundary},
+		{"multipart/mixed; boundary=\"a b\"; charset=utf-8", "multipart/mixed; boundary=" + CanonicalMultipartBoundary + "; charset=utf-8"},
+		{"application/json", "application/json"},
+		{"multipart/form-data", "multipart/form-data"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := canonicalContentType(tc.in); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package signature
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithAllowedCIDRs configures the Verifier to reject requests originating
+// outside the given networks, written in CIDR notation, such as "10.0.0.0/8",
+// with a 403 Error. Single IP addresses are also accepted. This is checked
+// before the signature, as a defense in depth for endpoints, such as webhooks,
+// that are only called from known networks.
+//
+// The origin of a request is its RemoteAddr, or the first address in its
+// X-Forwarded-For header if configured with WithForwardedFor. Requests passed
+// to a handler set by WithUnsignedHandler are not checked.
+//
+// If any network can not be parsed, NewVerifier returns the *net.ParseError.
+func WithAllowedCIDRs(cidrs ...string) Option {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	var parseErr error
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil {
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+				continue
+			}
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			parseErr = err
+			break
+		}
+		nets = append(nets, n)
+	}
+
+	return func(v *Verifier) {
+		if parseErr != nil {
+			v.optionErr = parseErr
+			return
+		}
+		v.allowedNets = nets
+	}
+}
+
+// WithForwardedFor configures the Verifier to take the origin of requests,
+// as checked against the networks set by WithAllowedCIDRs, from the first
+// address in their X-Forwarded-For header, if present. This should only be
+// used behind a proxy that sets the header, as clients may forge it.
+func WithForwardedFor() Option {
+	return func(v *Verifier) {
+		v.forwardedFor = true
+	}
+}
+
+// checkOrigin returns an error if req did not originate from one of the
+// Verifier's allowed networks.
+func (v *Verifier) checkOrigin(req *http.Request) error {
+	ip := v.origin(req)
+	for _, n := range v.allowedNets {
+		if ip != nil && n.Contains(ip) {
+			return nil
+		}
+	}
+
+	return &Error{Code: 403, Message: "Request did not originate from an allowed network", category: Forbidden}
+}
+
+// origin returns the IP address req originated from, or nil if it is not
+// known.
+func (v *Verifier) origin(req *http.Request) net.IP {
+	if v.forwardedFor {
+		if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
+			return net.ParseIP(strings.TrimSpace(strings.Split(xff, ",")[0]))
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
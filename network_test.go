@@ -0,0 +1,68 @@
+package signature
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithAllowedCIDRs(t *testing.T) {
+	keys := newTestKeys()
+
+	tcs := []struct {
+		name         string
+		opts         []Option
+		remoteAddr   string
+		forwardedFor string
+		code         int
+	}{
+		{"in range", []Option{WithAllowedCIDRs("10.0.0.0/8")}, "10.1.2.3:1234", "", 200},
+		{"out of range", []Option{WithAllowedCIDRs("10.0.0.0/8")}, "192.168.0.1:1234", "", 403},
+		{"second network", []Option{WithAllowedCIDRs("10.0.0.0/8", "192.168.0.0/16")}, "192.168.0.1:1234", "", 200},
+		{"single address", []Option{WithAllowedCIDRs("192.168.0.1")}, "192.168.0.1:1234", "", 200},
+		{"ipv6", []Option{WithAllowedCIDRs("fd00::/8")}, "[fd00::1]:1234", "", 200},
+		{"forwarded for ignored", []Option{WithAllowedCIDRs("10.0.0.0/8")}, "192.168.0.1:1234", "10.1.2.3", 403},
+		{"forwarded for in range", []Option{WithAllowedCIDRs("10.0.0.0/8"), WithForwardedFor()}, "192.168.0.1:1234", "10.1.2.3, 192.168.0.1", 200},
+		{"forwarded for out of range", []Option{WithAllowedCIDRs("10.0.0.0/8"), WithForwardedFor()}, "10.1.2.3:1234", "192.168.0.1", 403},
+		{"forwarded for absent", []Option{WithAllowedCIDRs("10.0.0.0/8"), WithForwardedFor()}, "10.1.2.3:1234", "", 200},
+		{"unknown origin", []Option{WithAllowedCIDRs("10.0.0.0/8")}, "", "", 403},
+		{"not configured", nil, "192.168.0.1:1234", "", 200},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+
+			rw := httptest.NewRecorder()
+			keys.verifier(t, tc.opts...).WrapFunc(func(http.ResponseWriter, *http.Request) {}).ServeHTTP(rw, req)
+
+			if rw.Code != tc.code {
+				t.Errorf("expected response code %d, got %d", tc.code, rw.Code)
+			}
+		})
+	}
+
+	t.Run("checked before signature", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.RemoteAddr = "192.168.0.1:1234"
+		req.Header.Del("X-Signature")
+
+		err := keys.verifier(t, WithAllowedCIDRs("10.0.0.0/8")).Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 403 {
+			t.Error("expected a 403 Error, got:", err)
+		}
+	})
+
+	t.Run("invalid network", func(t *testing.T) {
+		_, err := NewVerifier(keys.masterKey(), WithAllowedCIDRs("10.0.0.0/8", "10.0.0.0/33"))
+		if _, ok := err.(*net.ParseError); !ok {
+			t.Error("expected a *net.ParseError, got:", err)
+		}
+	})
+}
@@ -0,0 +1,510 @@
+package signature
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Option configures optional behaviour of a Verifier. Options are passed to
+// NewVerifier.
+type Option func(*Verifier)
+
+// WithBase64Body configures the Verifier to decode the request body from
+// base64url before canonicalizing it, for transports that encode the entire
+// body. The signature is expected to cover the decoded bytes.
+//
+// WithBase64Body was added in version 1.1.0.
+func WithBase64Body() Option {
+	return func(v *Verifier) {
+		v.canon.base64Body = true
+	}
+}
+
+// WithPlusAsSpace configures the Verifier to treat a '+' in the query string as
+// an encoded space, rewriting it to "%20" before the query is canonicalized.
+// This allows "?q=a+b" and "?q=a%20b" to verify against the same signature.
+//
+// The signer must apply the same normalization, signing the "%20" form.
+//
+// With WithDecodedQuery, a '+' is already decoded as a space.
+func WithPlusAsSpace() Option {
+	return func(v *Verifier) {
+		v.canon.plusAsSpace = true
+	}
+}
+
+// WithBodyTap configures the middleware to send a copy of the body of each
+// successfully verified request to ch, for asynchronous processing. Sends never
+// block; if ch is not ready to receive, the body is dropped. Bodies buffered to
+// disk by WithSpillToDisk are not sent, as they are not held in memory.
+func WithBodyTap(ch chan<- []byte) Option {
+	return func(v *Verifier) {
+		v.bodyTap = ch
+	}
+}
+
+// WithMinTLSVersion configures the Verifier to reject requests received over a
+// TLS connection older than version, one of the tls.VersionTLS constants, with
+// a 403 Error, even if they are validly signed. Requests over version itself
+// are accepted. Requests without TLS connection state are not affected.
+func WithMinTLSVersion(version uint16) Option {
+	return func(v *Verifier) {
+		v.minTLSVersion = version
+	}
+}
+
+// WithPathSlashNormalization configures the Verifier to collapse repeated
+// slashes in the request path before canonicalizing it, so "/v1//resources"
+// is canonicalized as "/v1/resources". This tolerates intermediaries that
+// add or collapse slashes.
+//
+// The signer must apply the same normalization.
+func WithPathSlashNormalization() Option {
+	return func(v *Verifier) {
+		v.canon.collapseSlashes = true
+	}
+}
+
+// WithSkewByPath configures the permitted time skew for requests by path
+// prefix. The window for the longest prefix matching the request path is used;
+// requests matching no prefix use PermittedTimeSkew.
+func WithSkewByPath(windows map[string]time.Duration) Option {
+	return func(v *Verifier) {
+		v.skewByPath = make(map[string]time.Duration, len(windows))
+		for prefix, d := range windows {
+			v.skewByPath[prefix] = d
+		}
+	}
+}
+
+// WithUnsignedHandler configures the middleware to pass requests without an
+// X-Signature header to h, rather than responding with an error. Requests that
+// are signed, but fail verification, are still rejected.
+func WithUnsignedHandler(h http.Handler) Option {
+	return func(v *Verifier) {
+		v.unsignedHandler = h
+	}
+}
+
+// WithDecodedPath configures the Verifier to canonicalize the request path in
+// its percent-decoded form, rather than as escaped on the wire, matching
+// signers that sign the decoded path.
+//
+// Decoding is lossy: distinct paths may share a decoded form, so a signature
+// for one is accepted for the others. Notably, "/a%2Fb" and "/a/b" both decode
+// to "/a/b". Handlers that route on the escaped path, or that treat an encoded
+// slash differently to a path separator, should not use this option.
+func WithDecodedPath() Option {
+	return func(v *Verifier) {
+		v.canon.decodedPath = true
+	}
+}
+
+// WithSortedSignedHeaders configures the Verifier to canonicalize the signed
+// headers in lexicographic order, rather than the order they are listed in
+// the X-Signed-Headers header. The value of X-Signed-Headers in the canonical
+// form is likewise sorted. This matches signers that sort the headers list.
+func WithSortedSignedHeaders() Option {
+	return func(v *Verifier) {
+		v.canon.sortedHeaders = true
+	}
+}
+
+// WithLowercaseHeaderNames configures the Verifier to lowercase the names
+// listed in the X-Signed-Headers header when canonicalizing its value, as
+// HTTP/2 requires of header names on the wire. Signed header names are always
+// lowercased in the canonical form, so with this option, the canonical form
+// does not depend on the case in which a signer lists its headers. Names
+// listed more than once are included in the value only once.
+func WithLowercaseHeaderNames() Option {
+	return func(v *Verifier) {
+		v.canon.lowercaseNames = true
+	}
+}
+
+// WithSignatureUseLimit configures the Verifier to accept each signature at
+// most n times, as a lightweight guard against replayed requests. Uses are
+// counted in memory, and only for requests that otherwise verify. A count is
+// kept for as long as its signature could be within the permitted time skew.
+//
+// If n is less than 1, NewVerifier returns an error.
+func WithSignatureUseLimit(n int) Option {
+	return func(v *Verifier) {
+		if n < 1 {
+			v.optionErr = fmt.Errorf("signature use limit must be at least 1, got %d", n)
+			return
+		}
+		v.uses = newUseCounter(n)
+	}
+}
+
+// WithRequestIDReplayKey configures the use limit set by
+// WithSignatureUseLimit to count uses by the request's signed X-Request-Id
+// header rather than by its signature, so that a retried request with a fresh
+// signature but the same id is also rejected. Requests without a signed
+// X-Request-Id are counted by signature.
+func WithRequestIDReplayKey() Option {
+	return func(v *Verifier) {
+		v.requestIDReplayKey = true
+	}
+}
+
+// WithNonceReplayKey configures the use limit set by WithSignatureUseLimit to
+// count uses by the request's signed X-Nonce header rather than by its
+// signature, so that a nonce can only be used once, even with a fresh
+// signature. Requests without a signed X-Nonce are counted by signature. The
+// nonce of a verified request is available from SignedNonce.
+func WithNonceReplayKey() Option {
+	return func(v *Verifier) {
+		v.nonceReplayKey = true
+	}
+}
+
+// WithStripBOM configures the Verifier to remove a leading UTF-8 byte order
+// mark from the request body before canonicalizing it. The signature is
+// expected to cover the body without the byte order mark.
+func WithStripBOM() Option {
+	return func(v *Verifier) {
+		v.canon.stripBOM = true
+	}
+}
+
+// WithAllowEmptySignedHeaders configures the Verifier to accept requests whose
+// X-Signed-Headers header lists no headers. By default these are rejected, as
+// such a signature covers neither the Date nor Host of the request.
+func WithAllowEmptySignedHeaders() Option {
+	return func(v *Verifier) {
+		v.allowEmptySignedHeaders = true
+	}
+}
+
+// WithCanonicalCookies configures the Verifier to canonicalize a signed Cookie
+// header by sorting its name=value pairs and delimiting them with "; ",
+// combining multiple Cookie headers. This matches signers that apply the same
+// normalization, allowing intermediaries to reorder cookies.
+func WithCanonicalCookies() Option {
+	return func(v *Verifier) {
+		v.canon.cookies = true
+	}
+}
+
+// WithRawPathHeader configures the Verifier to canonicalize the request path
+// from the named header, when it is present, rather than from the request URL.
+//
+// Canonize uses the escaped form of the request path, which survives routers
+// that decode the path while preserving URL.RawPath. A router or middleware
+// that rewrites URL.Path without RawPath loses the original encoding; this
+// option allows an ingress to preserve it in a header instead.
+//
+// The header must be set by a trusted proxy, which overwrites any value sent
+// by the client. Otherwise a signature could be replayed against a different
+// path.
+func WithRawPathHeader(name string) Option {
+	return func(v *Verifier) {
+		v.canon.rawPathHeader = name
+	}
+}
+
+// WithBodyFrame configures the Verifier to remove framing from the request
+// body before canonicalizing it, for protocols that wrap the signed payload,
+// for example with a length prefix. strip is given the full body, and returns
+// the payload covered by the signature. If strip returns an error, the request
+// is rejected.
+func WithBodyFrame(strip func([]byte) ([]byte, error)) Option {
+	return func(v *Verifier) {
+		v.canon.bodyFrame = strip
+	}
+}
+
+// WithAllowedContentTypes configures the Verifier to reject requests whose
+// Content-Type is not one of types with a 415 Error, before checking their
+// signature. Media type parameters, such as charset, are ignored.
+//
+// Requests without a body and without a Content-Type, such as most GET
+// requests, are not checked. Requests with a body but no Content-Type are
+// rejected.
+func WithAllowedContentTypes(types ...string) Option {
+	return func(v *Verifier) {
+		v.contentTypes = append([]string{}, types...)
+	}
+}
+
+// WithSplitSignatureHeaders configures the Verifier to accept a signature
+// split across the X-Signature-Value, X-Signature-Key, and
+// X-Signature-Endorsement headers, for gateways that can not carry spaces in a
+// header value. These are only used when the X-Signature header is absent.
+func WithSplitSignatureHeaders() Option {
+	return func(v *Verifier) {
+		v.splitSignature = true
+	}
+}
+
+// WithHostNormalization configures the Verifier to remove a single trailing
+// dot from the request host before canonicalizing it, so that a fully
+// qualified "example.com." verifies against a signature over "example.com".
+func WithHostNormalization() Option {
+	return func(v *Verifier) {
+		v.canon.hostNormalization = true
+	}
+}
+
+// WithDeadlineFromExpiry configures the middleware to set a deadline on the
+// context of verified requests carrying a signed X-Expires header, matching
+// the signature's expiry. This allows downstream handlers to stop processing a
+// request once its signature is no longer valid.
+func WithDeadlineFromExpiry() Option {
+	return func(v *Verifier) {
+		v.deadlineFromExpiry = true
+	}
+}
+
+// WithErrorJSONField configures the Verifier's middleware to serialize the
+// message of error responses under the JSON key name, rather than "message",
+// for consumers expecting a different format.
+func WithErrorJSONField(name string) Option {
+	return func(v *Verifier) {
+		v.errorField = name
+	}
+}
+
+// WithErrorResponder configures the Verifier's middleware to write error
+// responses with fn, rather than Error.Respond, for consumers expecting errors
+// in their own format. fn must write the response, including its status code,
+// which is typically e.Code.
+func WithErrorResponder(fn func(rw http.ResponseWriter, e *Error)) Option {
+	return func(v *Verifier) {
+		v.errorResponder = fn
+	}
+}
+
+// WithSignedQueryParams configures the Verifier to include only the query
+// parameters named in names in the canonical form of the request, ignoring
+// any others, such as cache-busters or tracking ids added in transit. The
+// signer must canonicalize the query in the same way.
+func WithSignedQueryParams(names ...string) Option {
+	return func(v *Verifier) {
+		v.canon.signedQueryParams = make(map[string]bool, len(names))
+		for _, n := range names {
+			v.canon.signedQueryParams[n] = true
+		}
+	}
+}
+
+// WithPreVerify configures the Verifier to call fn with each request before
+// verifying it, allowing cheap checks, such as an IP allowlist, to reject
+// requests before their signature is checked. An error returned by fn is
+// returned from Verify unchanged. The middleware responds with it if it is an
+// *Error, and with a 401 otherwise.
+func WithPreVerify(fn func(*http.Request) error) Option {
+	return func(v *Verifier) {
+		v.preVerify = fn
+	}
+}
+
+// WithReceiptTime configures the Verifier to measure the time skew of a
+// request against the time returned by fn, rather than the current time. This
+// allows requests to be verified after a delay, such as in queue based
+// pipelines, using a receipt time recorded at ingress:
+//
+//	signature.WithReceiptTime(func(req *http.Request) time.Time {
+//		t, _ := time.Parse(time.RFC3339, req.Header.Get("X-Received-At"))
+//		return t
+//	})
+//
+// If fn returns the zero time, the current time is used. fn must only return
+// receipt times from a trusted source, as they are not covered by the
+// signature.
+func WithReceiptTime(fn func(*http.Request) time.Time) Option {
+	return func(v *Verifier) {
+		v.receiptTime = fn
+	}
+}
+
+// WithAbsoluteTarget configures the Verifier to include the request's scheme
+// and host in the first line of its canonical form, matching signers that sign
+// the absolute URL, as in "get https://example.com/v1/resources?id=1". The
+// scheme of server requests is inferred from whether they were received over
+// TLS, so requests terminated by a proxy must restore it to req.URL.Scheme.
+//
+// WithAbsoluteTarget was added in version 1.1.0.
+func WithAbsoluteTarget() Option {
+	return func(v *Verifier) {
+		v.canon.absoluteTarget = true
+	}
+}
+
+// WithBodyDigest configures the Verifier to accept signatures that cover the
+// request body through a signed RFC 3230 Digest header, such as
+// "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", rather than the body
+// itself. When the Digest header is signed, the body is omitted from the
+// canonical form, and is instead checked against the header's SHA-256 digest.
+// Requests without a signed Digest header are canonicalized as usual.
+func WithBodyDigest() Option {
+	return func(v *Verifier) {
+		v.canon.bodyDigest = true
+	}
+}
+
+// WithPreSortedQuery configures the Verifier to canonicalize the request's
+// query parameters in the order they were sent, rather than sorting them, for
+// signers that sign the literal query. This preserves the relative order of
+// repeated parameters, and requires the query to reach the Verifier unaltered.
+func WithPreSortedQuery() Option {
+	return func(v *Verifier) {
+		v.canon.preSortedQuery = true
+	}
+}
+
+// WithDecodedQuery configures the Verifier to decode each query parameter
+// before sorting the query, sorting by decoded name and then value, and
+// re-encoding each parameter consistently, with spaces as "%20". This allows
+// queries that encode the same parameters differently, such as "?r=/c&q=a+b"
+// and "?q=a%20b&r=%2Fc", to verify against the same signature. Parameters that
+// can not be decoded are included as sent.
+//
+// The signer must apply the same normalization. WithPreSortedQuery takes
+// precedence, leaving the query as sent.
+func WithDecodedQuery() Option {
+	return func(v *Verifier) {
+		v.canon.decodedQuery = true
+	}
+}
+
+// WithCriticalHeaders configures the Verifier to reject requests carrying any
+// of the named headers unless they are signed, as an unsigned critical header,
+// such as Authorization, could have been added or altered in transit. Unlike a
+// required header, a critical header may be absent.
+func WithCriticalHeaders(names ...string) Option {
+	return func(v *Verifier) {
+		for _, n := range names {
+			v.criticalHeaders = append(v.criticalHeaders, http.CanonicalHeaderKey(n))
+		}
+	}
+}
+
+// WithPerKeyRateLimit configures the Verifier to allow at most n verification
+// attempts by each live public key in each window, rejecting further attempts
+// with a 429 Error before their signature is checked. Attempts are counted in
+// memory, whether or not they verify, but only once the live key's
+// endorsement has been checked, so that requests with unendorsed keys are
+// rejected without being counted. As the live key is taken from the request,
+// this limits abuse of a key, rather than of the Verifier.
+func WithPerKeyRateLimit(n int, window time.Duration) Option {
+	return func(v *Verifier) {
+		v.rateLimit = newRateLimiter(n, window)
+	}
+}
+
+// WithStructuredSignedHeaders configures the Verifier to read the
+// X-Signed-Headers header as an RFC 8941 structured field inner list of
+// header names, for signers that use that syntax:
+//
+//	X-Signed-Headers: ("host" "date" "content-type")
+//
+// The header value is included in the canonical form as sent.
+func WithStructuredSignedHeaders() Option {
+	return func(v *Verifier) {
+		v.canon.structuredHeaders = true
+	}
+}
+
+// WithVerifyTimeout configures the Verifier to bound the time taken to verify
+// a request, including reading the body given to Verify, or the request body
+// read by the middleware, to d, failing with a 408 Error once it is exceeded,
+// or once the request's context is done. Work in progress when the timeout is
+// reached, such as a blocked body read, is abandoned rather than interrupted,
+// so bodies should still be bounded by the server's read timeout. Abandoned
+// work stops reading the body once its current read returns, and is not
+// counted by WithSignatureUseLimit or WithPerKeyRateLimit, so the request may
+// be retried.
+func WithVerifyTimeout(d time.Duration) Option {
+	return func(v *Verifier) {
+		v.verifyTimeout = d
+	}
+}
+
+// WithLengthPrefixedBody configures the Verifier to expect a line of the form
+// "body: <length>" between the headers and the body in the canonical form,
+// where length is the number of body bytes in decimal. Binding the length into
+// the signature removes any ambiguity over where the headers end and the body
+// begins. The signer must canonicalize the request in the same way.
+func WithLengthPrefixedBody() Option {
+	return func(v *Verifier) {
+		v.canon.lengthPrefixedBody = true
+	}
+}
+
+// WithBodyTransforms configures the Verifier to apply fns to the request body
+// in order before canonicalizing it, after any other body normalization. Each
+// function is given the output of the one before, and the signer must apply
+// the same transforms. If any function returns an error, the request is
+// rejected. Repeated use appends to the list of transforms.
+func WithBodyTransforms(fns ...func([]byte) ([]byte, error)) Option {
+	return func(v *Verifier) {
+		v.canon.bodyTransforms = append(v.canon.bodyTransforms, fns...)
+	}
+}
+
+// WithSemanticErrorCode configures the Verifier to use code, such as 422,
+// rather than 400 for errors caused by well formed requests that are not
+// valid: those outside the permitted time skew, presented before their signed
+// not-before time, or after their signed expiry. Errors caused by malformed
+// requests keep the 400 code.
+func WithSemanticErrorCode(code int) Option {
+	return func(v *Verifier) {
+		v.semanticCode = code
+	}
+}
+
+// WithStrictHeaderCase configures the Verifier to require that the names
+// listed in the X-Signed-Headers header match the case of the request's header
+// names exactly, for signers that sign exact-case names. Requests whose signed
+// headers are present only with differently cased names are rejected. The
+// names in the canonical form are lowercased as usual.
+//
+// Header names are canonicalized when requests are read by net/http, so a
+// signer must list names in that form, such as "Content-Type", to verify
+// against server requests.
+func WithStrictHeaderCase() Option {
+	return func(v *Verifier) {
+		v.strictHeaderCase = true
+	}
+}
+
+// WithOptionalSignedHeaders configures the Verifier to reject requests missing
+// any header listed in their X-Signed-Headers header, other than the named
+// headers. The named headers may be absent, and are canonicalized with an
+// empty value, as all absent signed headers are by default.
+func WithOptionalSignedHeaders(names ...string) Option {
+	return func(v *Verifier) {
+		if v.optionalHeaders == nil {
+			v.optionalHeaders = make(map[string]bool, len(names))
+		}
+		for _, n := range names {
+			v.optionalHeaders[http.CanonicalHeaderKey(n)] = true
+		}
+	}
+}
+
+// WithSignedMaxSkew configures the Verifier to honor a signed X-Max-Skew
+// header, giving the permitted time skew in seconds, in place of the skew
+// window it would otherwise use. The declared window is capped at ceiling, so
+// signers may tighten the window, but not widen it beyond ceiling. Unsigned
+// X-Max-Skew headers are ignored.
+func WithSignedMaxSkew(ceiling time.Duration) Option {
+	return func(v *Verifier) {
+		v.maxSkewCeiling = ceiling
+	}
+}
+
+// WithClock configures the Verifier to read the current time from now, in
+// place of the system clock. It is used to check the time skew of requests and
+// responses, signed validity periods and endorsement certificates, to count
+// signature uses and rate limits, and to select keys from a ScheduleKeys
+// source. This allows tests to verify requests at a fixed time.
+func WithClock(now func() time.Time) Option {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
@@ -0,0 +1,1946 @@
+package signature
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithBase64Body(t *testing.T) {
+	keys := newTestKeys()
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(body))
+
+	t.Run("decodes body", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		v := keys.verifier(t, WithBase64Body())
+		if err := v.Verify(req, bytes.NewBufferString(encoded)); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("accepts padding", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		v := keys.verifier(t, WithBase64Body())
+		padded := base64.URLEncoding.EncodeToString([]byte(body))
+		if err := v.Verify(req, bytes.NewBufferString(padded)); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		v := keys.verifier(t)
+		if err := v.Verify(req, bytes.NewBufferString(encoded)); err == nil {
+			t.Error("expected encoded body to fail verification")
+		}
+	})
+
+	t.Run("invalid encoding", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		v := keys.verifier(t, WithBase64Body())
+		err := v.Verify(req, bytes.NewBufferString("not base64!"))
+		e, ok := err.(*Error)
+		if !ok || e.Code != 400 {
+			t.Error("expected a 400 Error, got:", err)
+		}
+	})
+}
+
+func TestWithPlusAsSpace(t *testing.T) {
+	keys := newTestKeys()
+
+	canon := &canonOptions{plusAsSpace: true}
+	plus, _ := http.NewRequest("GET", "/v1/resources?q=a+b&r=c", nil)
+	pct, _ := http.NewRequest("GET", "/v1/resources?q=a%20b&r=c", nil)
+
+	pb, err := canon.canonize(plus, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal("could not canonize request:", err)
+	}
+	cb, err := canon.canonize(pct, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal("could not canonize request:", err)
+	}
+
+	if !bytes.Equal(pb, cb) {
+		t.Errorf("canonical forms differ:\n%s\n%s", pb, cb)
+	}
+
+	t.Run("verifies plus encoding", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources?q=a%20b", "")
+		req.URL.RawQuery = "q=a+b"
+
+		v := keys.verifier(t, WithPlusAsSpace())
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources?q=a%20b", "")
+		req.URL.RawQuery = "q=a+b"
+
+		v := keys.verifier(t)
+		if err := v.Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected plus encoding to fail verification")
+		}
+	})
+
+	t.Run("pre-sorted query", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources?q=a%20b", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "host date")
+		b, _ := (&canonOptions{preSortedQuery: true}).canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		req.URL.RawQuery = "q=a+b"
+
+		if err := keys.verifier(t, WithPreSortedQuery(), WithPlusAsSpace()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t, WithPreSortedQuery()).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected plus encoding to fail verification")
+		}
+	})
+}
+
+func TestWithBodyTap(t *testing.T) {
+	keys := newTestKeys()
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+
+	t.Run("tapped", func(t *testing.T) {
+		ch := make(chan []byte, 1)
+		v := keys.verifier(t, WithBodyTap(ch))
+
+		var read []byte
+		h := v.WrapFunc(func(rw http.ResponseWriter, r *http.Request) {
+			read, _ = ioutil.ReadAll(r.Body)
+		})
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		select {
+		case b := <-ch:
+			if string(b) != body {
+				t.Errorf("tapped body did not match: %q", b)
+			}
+		default:
+			t.Fatal("body was not tapped")
+		}
+
+		if string(read) != body {
+			t.Errorf("handler body did not match: %q", read)
+		}
+	})
+
+	t.Run("full channel", func(t *testing.T) {
+		ch := make(chan []byte)
+		v := keys.verifier(t, WithBodyTap(ch))
+
+		var called bool
+		h := v.WrapFunc(func(http.ResponseWriter, *http.Request) {
+			called = true
+		})
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !called {
+			t.Error("handler was not called")
+		}
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		ch := make(chan []byte, 1)
+		v := keys.verifier(t, WithBodyTap(ch))
+		h := v.WrapFunc(func(http.ResponseWriter, *http.Request) {})
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		req.Header.Set("Date", "2017-03-05T23:53:09Z")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if len(ch) != 0 {
+			t.Error("body of invalid request was tapped")
+		}
+	})
+}
+
+func TestWithMinTLSVersion(t *testing.T) {
+	keys := newTestKeys()
+
+	tcs := []struct {
+		name    string
+		state   *tls.ConnectionState
+		success bool
+	}{
+		{"no tls", nil, true},
+		{"tls 1.1", &tls.ConnectionState{Version: tls.VersionTLS11}, false},
+		{"tls 1.2", &tls.ConnectionState{Version: tls.VersionTLS12}, true},
+		{"tls 1.3", &tls.ConnectionState{Version: tls.VersionTLS13}, true},
+	}
+
+	v := keys.verifier(t, WithMinTLSVersion(tls.VersionTLS12))
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+			req.TLS = tc.state
+
+			err := v.Verify(req, &bytes.Buffer{})
+			if tc.success && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.success {
+				if e, ok := err.(*Error); !ok || e.Code != 403 || e.Category() != Forbidden {
+					t.Error("expected a 403 Forbidden Error, got:", err)
+				}
+			}
+		})
+	}
+
+	t.Run("boundary", func(t *testing.T) {
+		v := keys.verifier(t, WithMinTLSVersion(tls.VersionTLS13))
+
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		req.TLS = &tls.ConnectionState{Version: tls.VersionTLS13}
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected the minimum version to verify, got:", err)
+		}
+
+		req.TLS = &tls.ConnectionState{Version: tls.VersionTLS13 - 1}
+		if e, ok := v.Verify(req, &bytes.Buffer{}).(*Error); !ok || e.Code != 403 {
+			t.Error("expected the version below the minimum to be rejected, got:", e)
+		}
+	})
+}
+
+func TestWithPathSlashNormalization(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{collapseSlashes: true}
+
+	tcs := []struct {
+		path     string
+		expected string
+	}{
+		{"/v1/resources", "/v1/resources"},
+		{"/v1//resources", "/v1/resources"},
+		{"//v1///resources", "/v1/resources"},
+		{"/v1/resources/", "/v1/resources/"},
+		{"/v1/resources//", "/v1/resources/"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://127.0.0.1:4567"+tc.path, nil)
+			b, err := canon.canonize(req, &bytes.Buffer{})
+			if err != nil {
+				t.Fatal("could not canonize request:", err)
+			}
+
+			line := strings.SplitN(string(b), "\n", 2)[0]
+			if line != "get "+tc.expected {
+				t.Errorf("unexpected request target %q", line)
+			}
+		})
+	}
+
+	t.Run("verifies doubled slashes", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		req.URL.Path = "/v1//resources"
+
+		v := keys.verifier(t, WithPathSlashNormalization())
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected doubled slashes to fail verification by default")
+		}
+	})
+}
+
+func TestWithSkewByPath(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithSkewByPath(map[string]time.Duration{
+		"/v1/batch":          time.Hour,
+		"/v1/batch/payments": time.Minute,
+	}))
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	timeSince = func(time.Time) time.Duration {
+		return 30 * time.Minute
+	}
+
+	tcs := []struct {
+		path    string
+		success bool
+	}{
+		{"/v1/batch/jobs", true},
+		{"/v1/batch/payments/1", false},
+		{"/v1/resources", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.path, func(t *testing.T) {
+			req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567"+tc.path, "")
+			err := v.Verify(req, &bytes.Buffer{})
+			if tc.success && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.success && err == nil {
+				t.Error("expected time skew to be rejected")
+			}
+		})
+	}
+}
+
+func TestWithUnsignedHandler(t *testing.T) {
+	keys := newTestKeys()
+
+	var unsigned, signed bool
+	v := keys.verifier(t, WithUnsignedHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		unsigned = true
+		rw.WriteHeader(http.StatusUpgradeRequired)
+	})))
+	h := v.WrapFunc(func(http.ResponseWriter, *http.Request) {
+		signed = true
+	})
+
+	tcs := []struct {
+		name     string
+		mutate   func(*http.Request)
+		code     int
+		unsigned bool
+		signed   bool
+	}{
+		{"signed", func(*http.Request) {}, 200, false, true},
+		{"unsigned", func(r *http.Request) { r.Header.Del("X-Signature") }, 426, true, false},
+		{"invalid", func(r *http.Request) { r.Header.Set("Date", "2017-03-05T23:53:09Z") }, 401, false, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			unsigned, signed = false, false
+			req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+			tc.mutate(req)
+
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			if rw.Code != tc.code {
+				t.Error("Wrong status code returned:", rw.Code)
+			}
+
+			if unsigned != tc.unsigned || signed != tc.signed {
+				t.Errorf("unexpected handlers called: unsigned=%t signed=%t", unsigned, signed)
+			}
+		})
+	}
+}
+
+func TestWithSortedSignedHeaders(t *testing.T) {
+	keys := newTestKeys()
+
+	req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signed-Headers", "host date content-type")
+
+	t.Run("preserved", func(t *testing.T) {
+		b, _ := Canonize(req, &bytes.Buffer{})
+		expected := "get /v1/resources\n" +
+			"host: 127.0.0.1:4567\n" +
+			"date: 2017-03-05T23:53:08Z\n" +
+			"content-type: application/json\n" +
+			"x-signed-headers: host date content-type\n"
+		if string(b) != expected {
+			t.Errorf("unexpected canonical form:\n%s", b)
+		}
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		b, _ := (&canonOptions{sortedHeaders: true}).canonize(req, &bytes.Buffer{})
+		expected := "get /v1/resources\n" +
+			"content-type: application/json\n" +
+			"date: 2017-03-05T23:53:08Z\n" +
+			"host: 127.0.0.1:4567\n" +
+			"x-signed-headers: content-type date host\n"
+		if string(b) != expected {
+			t.Errorf("unexpected canonical form:\n%s", b)
+		}
+
+		if req.Header.Get("X-Signed-Headers") != "host date content-type" {
+			t.Error("request header was modified")
+		}
+
+		keys.sign(req, b)
+		if err := keys.verifier(t, WithSortedSignedHeaders()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected sorted signature to fail verification by default")
+		}
+	})
+}
+
+func TestWithStripBOM(t *testing.T) {
+	keys := newTestKeys()
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+	bom := "\xEF\xBB\xBF"
+
+	tcs := []struct {
+		name    string
+		signed  string
+		body    string
+		opts    []Option
+		success bool
+	}{
+		{"with bom", body, bom + body, []Option{WithStripBOM()}, true},
+		{"without bom", body, body, []Option{WithStripBOM()}, true},
+		{"short body", "{}", "{}", []Option{WithStripBOM()}, true},
+		{"not enabled", body, bom + body, nil, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", tc.signed)
+			err := keys.verifier(t, tc.opts...).Verify(req, bytes.NewBufferString(tc.body))
+			if tc.success && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.success && err == nil {
+				t.Error("expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestWithAllowEmptySignedHeaders(t *testing.T) {
+	keys := newTestKeys()
+
+	newEmptyReq := func(list string) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", list)
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	for _, list := range []string{"", " "} {
+		t.Run("rejected by default", func(t *testing.T) {
+			err := keys.verifier(t).Verify(newEmptyReq(list), &bytes.Buffer{})
+			if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != "X-Signed-Headers header lists no headers" {
+				t.Error("expected empty signed headers to be rejected, got:", err)
+			}
+		})
+
+		t.Run("allowed", func(t *testing.T) {
+			err := keys.verifier(t, WithAllowEmptySignedHeaders()).Verify(newEmptyReq(list), &bytes.Buffer{})
+			if err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+		})
+	}
+
+	t.Run("canonical form", func(t *testing.T) {
+		b, _ := Canonize(newEmptyReq(""), &bytes.Buffer{})
+		// The empty name is kept, matching the canonical form of signers that
+		// split the list on spaces.
+		if string(b) != "get /v1/resources\n: \nx-signed-headers: \n" {
+			t.Errorf("unexpected canonical form: %q", b)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		req := newEmptyReq("")
+		req.Header.Del("X-Signed-Headers")
+
+		err := keys.verifier(t, WithAllowEmptySignedHeaders()).Verify(req, &bytes.Buffer{})
+		if e, ok := err.(*Error); !ok || e.Message != "Missing X-Signed-Headers header" {
+			t.Error("expected missing signed headers to be rejected, got:", err)
+		}
+	})
+}
+
+func TestWithCanonicalCookies(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{cookies: true}
+
+	tcs := [][]string{
+		{"a=1; b=2; c=3"},
+		{"c=3; a=1; b=2"},
+		{"b=2;a=1 ;  c=3;"},
+		{"c=3", "b=2; a=1"},
+	}
+
+	for _, cookies := range tcs {
+		t.Run(strings.Join(cookies, ", "), func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+			req.Header.Set("X-Signed-Headers", "cookie")
+			req.Header["Cookie"] = cookies
+
+			b, err := canon.canonize(req, &bytes.Buffer{})
+			if err != nil {
+				t.Fatal("could not canonize request:", err)
+			}
+
+			expected := "get /v1/resources\ncookie: a=1; b=2; c=3\nx-signed-headers: cookie\n"
+			if string(b) != expected {
+				t.Errorf("unexpected canonical form: %q", b)
+			}
+		})
+	}
+
+	t.Run("verifies reordered cookies", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "date cookie")
+		req.Header.Set("Cookie", "a=1; b=2")
+
+		b, _ := canon.canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		req.Header.Set("Cookie", "b=2; a=1")
+
+		if err := keys.verifier(t, WithCanonicalCookies()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected reordered cookies to fail verification by default")
+		}
+	})
+}
+
+func TestWithRawPathHeader(t *testing.T) {
+	keys := newTestKeys()
+
+	target := func(canon *canonOptions, req *http.Request) string {
+		b, err := canon.canonize(req, &bytes.Buffer{})
+		if err != nil {
+			t.Fatal("could not canonize request:", err)
+		}
+
+		return strings.SplitN(string(b), "\n", 2)[0]
+	}
+
+	t.Run("escaped path", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/a%2Fb", nil)
+		if line := target(&canonOptions{}, req); line != "get /a%2Fb" {
+			t.Errorf("unexpected request target %q", line)
+		}
+	})
+
+	t.Run("decoded path with raw path", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/", nil)
+		req.URL.Path = "/a/b"
+		req.URL.RawPath = "/a%2Fb"
+
+		if line := target(&canonOptions{}, req); line != "get /a%2Fb" {
+			t.Errorf("unexpected request target %q", line)
+		}
+	})
+
+	t.Run("rewritten path", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/a%2Fb", "")
+		req.URL.Path = "/a/b"
+		req.URL.RawPath = ""
+
+		if line := target(&canonOptions{}, req); line != "get /a/b" {
+			t.Errorf("unexpected request target %q", line)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected rewritten path to fail verification")
+		}
+
+		req.Header.Set("X-Original-Path", "/a%2Fb")
+		v := keys.verifier(t, WithRawPathHeader("X-Original-Path"))
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+}
+
+func TestWithBodyFrame(t *testing.T) {
+	keys := newTestKeys()
+	payload := "\x00\x01binary payload"
+
+	// lengthPrefixed strips a 4 byte big endian length prefix.
+	lengthPrefixed := func(b []byte) ([]byte, error) {
+		if len(b) < 4 {
+			return nil, errors.New("short frame")
+		}
+
+		n := binary.BigEndian.Uint32(b)
+		if int(n) != len(b)-4 {
+			return nil, errors.New("bad frame length")
+		}
+
+		return b[4:], nil
+	}
+
+	frame := func(p string) *bytes.Buffer {
+		b := make([]byte, 4, 4+len(p))
+		binary.BigEndian.PutUint32(b, uint32(len(p)))
+		return bytes.NewBuffer(append(b, p...))
+	}
+
+	t.Run("framed", func(t *testing.T) {
+		req := keys.newSignedReq(t, "POST", "https://127.0.0.1:4567/v1/stream", payload)
+		v := keys.verifier(t, WithBodyFrame(lengthPrefixed))
+		if err := v.Verify(req, frame(payload)); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("bad frame", func(t *testing.T) {
+		req := keys.newSignedReq(t, "POST", "https://127.0.0.1:4567/v1/stream", payload)
+		v := keys.verifier(t, WithBodyFrame(lengthPrefixed))
+		err := v.Verify(req, bytes.NewBufferString(payload))
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Error("expected a 400 Error, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		req := keys.newSignedReq(t, "POST", "https://127.0.0.1:4567/v1/stream", payload)
+		if err := keys.verifier(t).Verify(req, frame(payload)); err == nil {
+			t.Error("expected framed body to fail verification")
+		}
+	})
+}
+
+func TestWithAllowedContentTypes(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithAllowedContentTypes("application/json", "text/plain"))
+
+	tcs := []struct {
+		contentType string
+		success     bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Text/Plain", true},
+		{"application/xml", false},
+		{"", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.contentType, func(t *testing.T) {
+			req := keys.newSignedReq(t, "POST", "https://127.0.0.1:4567/v1/resources", "{}")
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			err := v.Verify(req, bytes.NewBufferString("{}"))
+			if tc.success && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.success {
+				if e, ok := err.(*Error); !ok || e.Code != 415 {
+					t.Error("expected a 415 Error, got:", err)
+				}
+			}
+		})
+	}
+
+	t.Run("no body", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected request without a body or Content-Type to verify, got:", err)
+		}
+
+		req.Header.Set("Content-Type", "application/xml")
+		if e, ok := v.Verify(req, &bytes.Buffer{}).(*Error); !ok || e.Code != 415 {
+			t.Error("expected a disallowed Content-Type without a body to be rejected, got:", e)
+		}
+	})
+
+	t.Run("chunked body", func(t *testing.T) {
+		req := keys.newSignedReq(t, "POST", "https://127.0.0.1:4567/v1/resources", "{}")
+		req.ContentLength = -1
+
+		if e, ok := v.Verify(req, bytes.NewBufferString("{}")).(*Error); !ok || e.Code != 415 {
+			t.Error("expected a body of unknown length without a Content-Type to be rejected, got:", e)
+		}
+	})
+}
+
+func TestWithSplitSignatureHeaders(t *testing.T) {
+	keys := newTestKeys()
+
+	split := func() *http.Request {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		parts := strings.Split(req.Header.Get("X-Signature"), " ")
+		req.Header.Del("X-Signature")
+		req.Header.Set("X-Signature-Value", parts[0])
+		req.Header.Set("X-Signature-Key", parts[1])
+		req.Header.Set("X-Signature-Endorsement", parts[2])
+		return req
+	}
+
+	t.Run("split", func(t *testing.T) {
+		v := keys.verifier(t, WithSplitSignatureHeaders())
+		if err := v.Verify(split(), &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		v := keys.verifier(t, WithSplitSignatureHeaders())
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		v := keys.verifier(t, WithSplitSignatureHeaders())
+		req := split()
+		req.Header.Del("X-Signature-Endorsement")
+
+		err := v.Verify(req, &bytes.Buffer{})
+		if e, ok := err.(*Error); !ok || e.Message != "Missing X-Signature header" {
+			t.Error("expected missing signature error, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		if err := keys.verifier(t).Verify(split(), &bytes.Buffer{}); err == nil {
+			t.Error("expected split signature to be rejected by default")
+		}
+	})
+}
+
+func TestWithHostNormalization(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{hostNormalization: true}
+
+	tcs := []struct {
+		host     string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{"example.com.", "example.com"},
+		{"example.com.:8443", "example.com:8443"},
+		{"example.com:8443", "example.com:8443"},
+		{"[::1]:8443", "[::1]:8443"},
+		{"[::1]", "[::1]"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.host, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/v1/resources", nil)
+			req.Host = tc.host
+			req.Header.Set("X-Signed-Headers", "host")
+
+			b, _ := canon.canonize(req, &bytes.Buffer{})
+			expected := "get /v1/resources\nhost: " + tc.expected + "\nx-signed-headers: host\n"
+			if string(b) != expected {
+				t.Errorf("unexpected canonical form: %q", b)
+			}
+		})
+	}
+
+	t.Run("verifies trailing dot", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://example.com/v1/resources", "")
+		req.Host = "example.com."
+
+		if err := keys.verifier(t, WithHostNormalization()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected trailing dot to fail verification by default")
+		}
+	})
+}
+
+func TestWithDeadlineFromExpiry(t *testing.T) {
+	keys := newTestKeys()
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	timeSince = func(rt time.Time) time.Duration {
+		return time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC).Sub(rt)
+	}
+
+	newReq := func(signedHeaders string) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", http.NoBody)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Expires", "2017-03-05T23:58:08Z")
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	serve := func(v *Verifier, req *http.Request) (deadline time.Time, ok bool) {
+		rw := httptest.NewRecorder()
+		v.WrapFunc(func(_ http.ResponseWriter, r *http.Request) {
+			deadline, ok = r.Context().Deadline()
+		}).ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatal("unexpected response code:", rw.Code)
+		}
+		return deadline, ok
+	}
+
+	t.Run("signed expiry", func(t *testing.T) {
+		v := keys.verifier(t, WithDeadlineFromExpiry())
+		deadline, ok := serve(v, newReq("host date x-expires"))
+		if !ok {
+			t.Fatal("expected request context to have a deadline")
+		}
+
+		expected := time.Date(2017, 3, 5, 23, 58, 8, 0, time.UTC)
+		if !deadline.Equal(expected) {
+			t.Errorf("expected deadline %s, got %s", expected, deadline)
+		}
+	})
+
+	t.Run("unsigned expiry", func(t *testing.T) {
+		v := keys.verifier(t, WithDeadlineFromExpiry())
+		if _, ok := serve(v, newReq("host date")); ok {
+			t.Error("expected no deadline for unsigned expiry")
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		if _, ok := serve(keys.verifier(t), newReq("host date x-expires")); ok {
+			t.Error("expected no deadline by default")
+		}
+	})
+}
+
+func TestWithErrorJSONField(t *testing.T) {
+	keys := newTestKeys()
+	handler := func(http.ResponseWriter, *http.Request) {}
+
+	tcs := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{"default", nil, `{"message":"Missing X-Signature header"}`},
+		{"custom", []Option{WithErrorJSONField("error")}, `{"error":"Missing X-Signature header"}`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", http.NoBody)
+
+			rw := httptest.NewRecorder()
+			keys.verifier(t, tc.opts...).WrapFunc(handler).ServeHTTP(rw, req)
+
+			if rw.Code != 400 {
+				t.Error("unexpected response code:", rw.Code)
+			}
+
+			if rw.Body.String() != tc.expected {
+				t.Error("unexpected body:", rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithErrorResponder(t *testing.T) {
+	keys := newTestKeys()
+	handler := func(http.ResponseWriter, *http.Request) {}
+
+	categories := map[Category]string{Malformed: "bad_request", Unauthorized: "unauthorized"}
+	responder := func(rw http.ResponseWriter, e *Error) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(e.Code)
+		fmt.Fprintf(rw, `{"error":{"code":%q,"detail":%q}}`, categories[e.Category()], e.Message)
+	}
+
+	t.Run("missing signature", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", http.NoBody)
+
+		rw := httptest.NewRecorder()
+		keys.verifier(t, WithErrorResponder(responder), WithErrorJSONField("ignored")).WrapFunc(handler).ServeHTTP(rw, req)
+
+		if rw.Code != 400 {
+			t.Error("unexpected response code:", rw.Code)
+		}
+
+		expected := `{"error":{"code":"bad_request","detail":"Missing X-Signature header"}}`
+		if rw.Body.String() != expected {
+			t.Error("unexpected body:", rw.Body.String())
+		}
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Body = ioutil.NopCloser(bytes.NewBufferString("altered"))
+
+		rw := httptest.NewRecorder()
+		keys.verifier(t, WithErrorResponder(responder)).WrapFunc(handler).ServeHTTP(rw, req)
+
+		if rw.Code != 401 {
+			t.Error("unexpected response code:", rw.Code)
+		}
+
+		expected := `{"error":{"code":"unauthorized","detail":"Request was not signed by included Public Key"}}`
+		if rw.Body.String() != expected {
+			t.Error("unexpected body:", rw.Body.String())
+		}
+	})
+}
+
+func TestWithSignedQueryParams(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{signedQueryParams: map[string]bool{"id": true, "page size": true}}
+
+	tcs := []struct {
+		query    string
+		expected string
+	}{
+		{"id=1", "get /v1/resources?id=1\n"},
+		{"utm_source=x&id=1", "get /v1/resources?id=1\n"},
+		{"page+size=10&id=1&_=123", "get /v1/resources?id=1&page+size=10\n"},
+		{"page%20size=10", "get /v1/resources?page%20size=10\n"},
+		{"utm_source=x", "get /v1/resources\n"},
+		{"", "get /v1/resources\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/v1/resources?"+tc.query, nil)
+
+			var b bytes.Buffer
+			canon.writeTarget(&b, req)
+			if b.String() != tc.expected {
+				t.Errorf("unexpected target: %q", b.String())
+			}
+		})
+	}
+
+	t.Run("verifies with unsigned param", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources?id=1", "")
+		req.URL.RawQuery += "&utm_source=newsletter"
+
+		v := keys.verifier(t, WithSignedQueryParams("id"))
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected added param to fail verification by default")
+		}
+	})
+
+	t.Run("rejects altered signed param", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources?id=1", "")
+		req.URL.RawQuery = "id=2"
+
+		v := keys.verifier(t, WithSignedQueryParams("id"))
+		if err := v.Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected altered param to fail verification")
+		}
+	})
+}
+
+func TestWithPreVerify(t *testing.T) {
+	keys := newTestKeys()
+	denied := &Error{Code: 403, Message: "Address not allowed"}
+
+	allowlist := func(req *http.Request) error {
+		if req.RemoteAddr != "10.0.0.1:1234" {
+			return denied
+		}
+		return nil
+	}
+
+	t.Run("rejects before verification", func(t *testing.T) {
+		// An unsigned request would otherwise fail with a missing header.
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+
+		err := keys.verifier(t, WithPreVerify(allowlist)).Verify(req, &bytes.Buffer{})
+		if err != denied {
+			t.Error("expected pre-verify error, got:", err)
+		}
+	})
+
+	t.Run("passes to verification", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		req.RemoteAddr = "10.0.0.1:1234"
+
+		if err := keys.verifier(t, WithPreVerify(allowlist)).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		req.Header.Del("X-Signature")
+		if err := keys.verifier(t, WithPreVerify(allowlist)).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected unsigned request to fail verification")
+		}
+	})
+
+	t.Run("middleware response", func(t *testing.T) {
+		tcs := []struct {
+			name string
+			err  error
+			code int
+		}{
+			{"error", denied, 403},
+			{"other error", errors.New("nope"), 401},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				hook := func(*http.Request) error { return tc.err }
+				req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+				req.Body = http.NoBody
+
+				rw := httptest.NewRecorder()
+				keys.verifier(t, WithPreVerify(hook)).WrapFunc(func(http.ResponseWriter, *http.Request) {
+					t.Error("expected request not to be passed on")
+				}).ServeHTTP(rw, req)
+
+				if rw.Code != tc.code {
+					t.Error("unexpected response code:", rw.Code)
+				}
+			})
+		}
+	})
+}
+
+func TestWithReceiptTime(t *testing.T) {
+	keys := newTestKeys()
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC).Add(time.Hour)
+	timeSince = func(rt time.Time) time.Duration {
+		return now.Sub(rt)
+	}
+
+	receivedAt := func(req *http.Request) time.Time {
+		t, _ := time.Parse(time.RFC3339, req.Header.Get("X-Received-At"))
+		return t
+	}
+
+	tcs := []struct {
+		name     string
+		received string
+		opts     []Option
+		valid    bool
+	}{
+		{"received in window", "2017-03-05T23:54:08Z", []Option{WithReceiptTime(receivedAt)}, true},
+		{"received late", "2017-03-06T00:03:08Z", []Option{WithReceiptTime(receivedAt)}, false},
+		{"received early", "2017-03-05T23:43:08Z", []Option{WithReceiptTime(receivedAt)}, false},
+		{"no receipt time", "", []Option{WithReceiptTime(receivedAt)}, false},
+		{"not enabled", "2017-03-05T23:54:08Z", nil, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+			if tc.received != "" {
+				req.Header.Set("X-Received-At", tc.received)
+			}
+
+			err := keys.verifier(t, tc.opts...).Verify(req, &bytes.Buffer{})
+			if tc.valid && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.valid {
+				if e, ok := err.(*Error); !ok || e.Message != "Request time skew is too great" {
+					t.Error("expected time skew error, got:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithAbsoluteTarget(t *testing.T) {
+	keys := newTestKeys()
+
+	newServerReq := func(target string, tls bool) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		if !tls {
+			req.TLS = nil
+		}
+		return req
+	}
+
+	tcs := []struct {
+		name     string
+		req      *http.Request
+		relative string
+		absolute string
+	}{
+		{
+			"client request",
+			httptest.NewRequest("GET", "https://example.com/v1/resources?b=2&a=1", nil),
+			"get /v1/resources?a=1&b=2\n",
+			"get https://example.com/v1/resources?a=1&b=2\n",
+		},
+		{
+			"server request over tls",
+			newServerReq("https://example.com:8443/v1/resources", true),
+			"get /v1/resources\n",
+			"get https://example.com:8443/v1/resources\n",
+		},
+		{
+			"server request",
+			newServerReq("http://example.com/v1/resources", false),
+			"get /v1/resources\n",
+			"get http://example.com/v1/resources\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var rel, abs bytes.Buffer
+			(&canonOptions{}).writeTarget(&rel, tc.req)
+			(&canonOptions{absoluteTarget: true}).writeTarget(&abs, tc.req)
+
+			if rel.String() != tc.relative {
+				t.Errorf("unexpected relative target: %q", rel.String())
+			}
+
+			if abs.String() != tc.absolute {
+				t.Errorf("unexpected absolute target: %q", abs.String())
+			}
+		})
+	}
+
+	t.Run("verifies", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://example.com/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "date")
+
+		b, _ := (&canonOptions{absoluteTarget: true}).canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+
+		if err := keys.verifier(t, WithAbsoluteTarget()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected absolute signature to fail relative verification")
+		}
+
+		req.Host = "other.example.com"
+		if err := keys.verifier(t, WithAbsoluteTarget()).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected altered host to fail verification")
+		}
+	})
+}
+
+func TestWithPreSortedQuery(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{preSortedQuery: true}
+
+	tcs := []struct {
+		query    string
+		expected string
+	}{
+		{"a=1&b=2", "get /v1/resources?a=1&b=2\n"},
+		{"b=2&a=1", "get /v1/resources?b=2&a=1\n"},
+		{"tag=z&tag=x&tag=y", "get /v1/resources?tag=z&tag=x&tag=y\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/v1/resources?"+tc.query, nil)
+
+			var b bytes.Buffer
+			canon.writeTarget(&b, req)
+			if b.String() != tc.expected {
+				t.Errorf("unexpected target: %q", b.String())
+			}
+		})
+	}
+
+	t.Run("verifies literal query", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources?tag=z&tag=x", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "host date")
+
+		b, _ := canon.canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+
+		if err := keys.verifier(t, WithPreSortedQuery()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected literal query signature to fail sorted verification")
+		}
+
+		req.URL.RawQuery = "tag=x&tag=z"
+		if err := keys.verifier(t, WithPreSortedQuery()).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected reordered query to fail verification")
+		}
+	})
+}
+
+func TestWithDecodedQuery(t *testing.T) {
+	keys := newTestKeys()
+
+	signed := func(t *testing.T, query string) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources?"+query, nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "host date")
+
+		b, _ := (&canonOptions{decodedQuery: true}).canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	t.Run("equivalent encodings", func(t *testing.T) {
+		req := signed(t, "q=a%20b&r=%2Fc")
+		req.URL.RawQuery = "r=/c&q=a+b"
+
+		if err := keys.verifier(t, WithDecodedQuery()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected differently encoded query to fail raw verification")
+		}
+	})
+
+	t.Run("with plus as space", func(t *testing.T) {
+		v := keys.verifier(t, WithDecodedQuery(), WithPlusAsSpace())
+
+		for _, q := range []string{"q=a+b&r=c", "r=c&q=a%20b", "q=a+b&r=%63"} {
+			req := signed(t, "q=a%20b&r=c")
+			req.URL.RawQuery = q
+			if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+				t.Errorf("expected query %q to verify, got: %s", q, err)
+			}
+		}
+
+		req := signed(t, "q=a%2Bb")
+		req.URL.RawQuery = "q=a+b"
+		if err := v.Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected an encoded plus not to match a space")
+		}
+	})
+
+	t.Run("pre-sorted query", func(t *testing.T) {
+		canon := &canonOptions{decodedQuery: true, preSortedQuery: true}
+		req, _ := http.NewRequest("GET", "https://example.com/v1/resources?b=%32&a=1", nil)
+
+		var b bytes.Buffer
+		canon.writeTarget(&b, req)
+		if b.String() != "get /v1/resources?b=%32&a=1\n" {
+			t.Errorf("unexpected target: %q", b.String())
+		}
+	})
+}
+
+func TestWithCriticalHeaders(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithCriticalHeaders("authorization", "X-Api-Version"))
+
+	newAuthReq := func(signedHeaders string) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Authorization", "Bearer abc")
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	t.Run("unsigned critical header", func(t *testing.T) {
+		err := v.Verify(newAuthReq("host date"), &bytes.Buffer{})
+		if e, ok := err.(*Error); !ok || e.Code != 401 || e.Message != "Critical header Authorization was not signed" {
+			t.Error("expected unsigned critical header to be rejected, got:", err)
+		}
+	})
+
+	t.Run("signed critical header", func(t *testing.T) {
+		if err := v.Verify(newAuthReq("host date authorization"), &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("absent critical header", func(t *testing.T) {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		if err := keys.verifier(t).Verify(newAuthReq("host date"), &bytes.Buffer{}); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+}
+
+func TestWithStructuredSignedHeaders(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithStructuredSignedHeaders())
+
+	newStructuredReq := func(list string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signed-Headers", list)
+
+		b, _ := (&canonOptions{structuredHeaders: true}).canonize(req, bytes.NewBufferString("{}"))
+		keys.sign(req, b)
+		return req
+	}
+
+	t.Run("canonical form", func(t *testing.T) {
+		req := newStructuredReq(`("host" "date" "content-type")`)
+		b, _ := (&canonOptions{structuredHeaders: true}).canonize(req, bytes.NewBufferString("{}"))
+
+		expected := "put /v1/resources\n" +
+			"host: 127.0.0.1:4567\n" +
+			"date: 2017-03-05T23:53:08Z\n" +
+			"content-type: application/json\n" +
+			"x-signed-headers: (\"host\" \"date\" \"content-type\")\n" +
+			"{}"
+		if string(b) != expected {
+			t.Errorf("unexpected canonical form: %q", b)
+		}
+	})
+
+	t.Run("verifies", func(t *testing.T) {
+		req := newStructuredReq(`("host" "date" "content-type")`)
+		if err := v.Verify(req, bytes.NewBufferString("{}")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		req.Header.Set("Content-Type", "text/plain")
+		if err := v.Verify(req, bytes.NewBufferString("{}")); err == nil {
+			t.Error("expected altered signed header to fail verification")
+		}
+	})
+
+	t.Run("signed expiry", func(t *testing.T) {
+		req := newStructuredReq(`("host" "date" "x-expires")`)
+		req.Header.Set("X-Expires", "2017-03-05T23:53:07Z")
+
+		err := v.Verify(req, bytes.NewBufferString("{}"))
+		if e, ok := err.(*Error); !ok || e.Message != "Request signature has expired" {
+			t.Error("expected structured list to cover X-Expires, got:", err)
+		}
+	})
+
+	t.Run("invalid list", func(t *testing.T) {
+		req := newStructuredReq("host date")
+		err := v.Verify(req, bytes.NewBufferString("{}"))
+		if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != "Could not parse X-Signed-Headers header" {
+			t.Error("expected parse error, got:", err)
+		}
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		req := newStructuredReq("()")
+		err := v.Verify(req, bytes.NewBufferString("{}"))
+		if e, ok := err.(*Error); !ok || e.Message != "X-Signed-Headers header lists no headers" {
+			t.Error("expected empty list error, got:", err)
+		}
+	})
+}
+
+// slowReader reads from r, waiting on wait before the first read.
+type slowReader struct {
+	r    io.Reader
+	wait <-chan struct{}
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	<-s.wait
+	return s.r.Read(p)
+}
+
+func TestWithVerifyTimeout(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, WithVerifyTimeout(10*time.Millisecond), signedAt)
+
+	t.Run("slow body", func(t *testing.T) {
+		wait := make(chan struct{})
+		defer close(wait)
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		err := v.Verify(req, &slowReader{r: strings.NewReader("body"), wait: wait})
+		if e, ok := err.(*Error); !ok || e.Code != http.StatusRequestTimeout {
+			t.Error("expected timeout error, got:", err)
+		}
+	})
+
+	t.Run("within budget", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		if err := v.Verify(req, strings.NewReader("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := v.Verify(req, strings.NewReader("other")); err == nil || err.(*Error).Code != 401 {
+			t.Error("expected verification error, got:", err)
+		}
+	})
+
+	t.Run("abandoned verification", func(t *testing.T) {
+		v := keys.verifier(t, WithVerifyTimeout(10*time.Millisecond), WithSignatureUseLimit(1),
+			WithPerKeyRateLimit(1, time.Hour), signedAt)
+
+		wait := make(chan struct{})
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		err := v.Verify(req, &slowReader{r: strings.NewReader("body"), wait: wait})
+		if e, ok := err.(*Error); !ok || e.Code != http.StatusRequestTimeout {
+			t.Fatal("expected timeout error, got:", err)
+		}
+
+		// Let the abandoned verification finish reading before retrying.
+		close(wait)
+		time.Sleep(20 * time.Millisecond)
+
+		if err := v.Verify(req, strings.NewReader("body")); err != nil {
+			t.Error("expected retry to verify, got:", err)
+		}
+	})
+
+	t.Run("cancelled request", func(t *testing.T) {
+		wait := make(chan struct{})
+		defer close(wait)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req = req.WithContext(ctx)
+
+		v := keys.verifier(t, WithVerifyTimeout(time.Hour))
+		err := v.Verify(req, &slowReader{r: strings.NewReader("body"), wait: wait})
+		if e, ok := err.(*Error); !ok || e.Code != http.StatusRequestTimeout {
+			t.Error("expected timeout error, got:", err)
+		}
+	})
+
+	t.Run("middleware slow body", func(t *testing.T) {
+		wait := make(chan struct{})
+		defer close(wait)
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Body = ioutil.NopCloser(&slowReader{r: strings.NewReader("body"), wait: wait})
+
+		var called bool
+		h := keys.verifier(t, WithVerifyTimeout(20*time.Millisecond), signedAt).Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			called = true
+		}))
+
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+			done <- rw
+		}()
+
+		select {
+		case rw := <-done:
+			if rw.Code != http.StatusRequestTimeout {
+				t.Errorf("expected a 408 response, got %d: %s", rw.Code, rw.Body)
+			}
+			if called {
+				t.Error("expected handler not to be called")
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Error("expected the body read to be bounded by the verify timeout")
+		}
+	})
+}
+
+func TestWithLengthPrefixedBody(t *testing.T) {
+	keys := newTestKeys()
+	canon := &canonOptions{lengthPrefixedBody: true}
+	v := keys.verifier(t, WithLengthPrefixedBody())
+
+	for _, size := range []int{0, 1, 9, 10, 1024, 1 << 20} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			body := strings.Repeat("a", size)
+
+			req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+			req.Header.Set("Date", "2017-03-05T23:53:08Z")
+			req.Header.Set("X-Signed-Headers", "date")
+
+			b, err := canon.canonize(req, strings.NewReader(body))
+			if err != nil {
+				t.Fatal("could not canonize request:", err)
+			}
+
+			prefix := "put /v1/resources\n" +
+				"date: 2017-03-05T23:53:08Z\n" +
+				"x-signed-headers: date\n" +
+				"body: " + strconv.Itoa(size) + "\n"
+			if string(b) != prefix+body {
+				t.Errorf("unexpected canonical form: %.100q", b)
+			}
+
+			keys.sign(req, b)
+			if err := v.Verify(req, strings.NewReader(body)); err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if err := keys.verifier(t).Verify(req, strings.NewReader(body)); err == nil {
+				t.Error("expected length prefixed signature to fail default verification")
+			}
+		})
+	}
+}
+
+func TestWithBodyTransforms(t *testing.T) {
+	keys := newTestKeys()
+
+	trim := func(b []byte) ([]byte, error) {
+		return bytes.TrimSpace(b), nil
+	}
+	gunzip := func(b []byte) ([]byte, error) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(zr)
+	}
+
+	// The signer signs the trimmed, decompressed body.
+	payload := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+	req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", payload)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("\n  " + payload + "\n"))
+	zw.Close()
+
+	t.Run("composed", func(t *testing.T) {
+		v := keys.verifier(t, WithBodyTransforms(gunzip, trim))
+		if err := v.Verify(req, bytes.NewReader(gz.Bytes())); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("appended", func(t *testing.T) {
+		v := keys.verifier(t, WithBodyTransforms(gunzip), WithBodyTransforms(trim))
+		if err := v.Verify(req, bytes.NewReader(gz.Bytes())); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("order", func(t *testing.T) {
+		v := keys.verifier(t, WithBodyTransforms(trim, gunzip))
+		if err := v.Verify(req, bytes.NewReader(gz.Bytes())); err == nil {
+			t.Error("expected misordered transforms to fail verification")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		v := keys.verifier(t, WithBodyTransforms(gunzip, trim))
+		err := v.Verify(req, bytes.NewBufferString(payload))
+		if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != "Could not transform request body" {
+			t.Error("expected transform error, got:", err)
+		}
+	})
+}
+
+func TestWithSemanticErrorCode(t *testing.T) {
+	keys := newTestKeys()
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	timeSince = func(rt time.Time) time.Duration {
+		return now.Sub(rt)
+	}
+
+	newReq := func(header, value string) *http.Request {
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+		req.Header.Set(header, value)
+		if header != "Date" {
+			req.Header.Set("X-Signed-Headers", "host date "+strings.ToLower(header))
+		}
+		return req
+	}
+
+	tcs := []struct {
+		name     string
+		req      *http.Request
+		semantic bool
+	}{
+		{"skew", newReq("Date", "2017-03-05T23:43:08Z"), true},
+		{"not before", newReq("X-Not-Before", "2017-03-05T23:54:08Z"), true},
+		{"expired", newReq("X-Expires", "2017-03-05T23:52:08Z"), true},
+		{"unparseable date", newReq("Date", "yesterday"), false},
+		{"unparseable expiry", newReq("X-Expires", "tomorrow"), false},
+		{"missing signature", newReq("X-Signature", ""), false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := 400
+			if tc.semantic {
+				expected = http.StatusUnprocessableEntity
+			}
+
+			v := keys.verifier(t, WithSemanticErrorCode(http.StatusUnprocessableEntity))
+			err := v.Verify(tc.req, &bytes.Buffer{})
+			if e, ok := err.(*Error); !ok || e.Code != expected {
+				t.Errorf("expected %d error, got: %v", expected, err)
+			}
+
+			err = keys.verifier(t).Verify(tc.req, &bytes.Buffer{})
+			if e, ok := err.(*Error); !ok || e.Code != 400 {
+				t.Error("expected 400 error by default, got:", err)
+			}
+		})
+	}
+}
+
+func TestWithStrictHeaderCase(t *testing.T) {
+	keys := newTestKeys()
+
+	newCaseReq := func(signedHeaders string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	tcs := []struct {
+		name          string
+		signedHeaders string
+		strict        bool
+		valid         bool
+	}{
+		{"matching case", "host Date Content-Type", true, true},
+		{"mismatching case", "host date content-type", true, false},
+		{"absent header", "host Date X-Absent", true, true},
+		{"matching case, default", "host Date Content-Type", false, true},
+		{"mismatching case, default", "host date content-type", false, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []Option
+			if tc.strict {
+				opts = append(opts, WithStrictHeaderCase())
+			}
+
+			err := keys.verifier(t, opts...).Verify(newCaseReq(tc.signedHeaders), &bytes.Buffer{})
+			if tc.valid && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.valid {
+				if e, ok := err.(*Error); !ok || e.Code != 401 || !strings.Contains(e.Message, "case") {
+					t.Error("expected header case error, got:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithOptionalSignedHeaders(t *testing.T) {
+	keys := newTestKeys()
+
+	newOptionalReq := func(signedHeaders string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	tcs := []struct {
+		name          string
+		signedHeaders string
+		opts          []Option
+		valid         bool
+	}{
+		{"all present", "host date content-type", []Option{WithOptionalSignedHeaders("x-request-id")}, true},
+		{"optional absent", "host date x-request-id", []Option{WithOptionalSignedHeaders("x-request-id")}, true},
+		{"required absent", "host date content-length", []Option{WithOptionalSignedHeaders("x-request-id")}, false},
+		{"no optional headers", "host date x-request-id", []Option{WithOptionalSignedHeaders()}, false},
+		{"absent, default", "host date content-length", nil, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := keys.verifier(t, tc.opts...).Verify(newOptionalReq(tc.signedHeaders), &bytes.Buffer{})
+			if tc.valid && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if !tc.valid {
+				if e, ok := err.(*Error); !ok || e.Code != 400 || !strings.Contains(e.Message, "missing") {
+					t.Error("expected missing header error, got:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithSignedMaxSkew(t *testing.T) {
+	keys := newTestKeys()
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	timeSince = func(rt time.Time) time.Duration {
+		return time.Date(2017, 3, 5, 23, 55, 8, 0, time.UTC).Sub(rt)
+	}
+
+	newSkewReq := func(maxSkew, signedHeaders string) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Max-Skew", maxSkew)
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	tcs := []struct {
+		name          string
+		maxSkew       string
+		signedHeaders string
+		opts          []Option
+		code          int
+	}{
+		{"within signed window", "180", "host date x-max-skew", []Option{WithSignedMaxSkew(PermittedTimeSkew)}, 0},
+		{"outside signed window", "60", "host date x-max-skew", []Option{WithSignedMaxSkew(PermittedTimeSkew)}, 400},
+		{"capped at ceiling", "3600", "host date x-max-skew", []Option{WithSignedMaxSkew(time.Minute)}, 400},
+		{"unsigned", "60", "host date", []Option{WithSignedMaxSkew(PermittedTimeSkew)}, 0},
+		{"invalid", "soon", "host date x-max-skew", []Option{WithSignedMaxSkew(PermittedTimeSkew)}, 400},
+		{"negative", "-60", "host date x-max-skew", []Option{WithSignedMaxSkew(PermittedTimeSkew)}, 400},
+		{"not enabled", "60", "host date x-max-skew", nil, 0},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := keys.verifier(t, tc.opts...).Verify(newSkewReq(tc.maxSkew, tc.signedHeaders), &bytes.Buffer{})
+			if tc.code == 0 && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if tc.code != 0 {
+				if e, ok := err.(*Error); !ok || e.Code != tc.code {
+					t.Errorf("expected a %d Error, got: %v", tc.code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	keys := newTestKeys()
+
+	newClockReq := func() *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Expires", "2017-03-05T23:55:08Z")
+		req.Header.Set("X-Signed-Headers", "host date x-expires")
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	tcs := []struct {
+		name    string
+		now     time.Time
+		message string
+	}{
+		{"within window", time.Date(2017, 3, 5, 23, 54, 8, 0, time.UTC), ""},
+		{"before window", time.Date(2017, 3, 5, 23, 47, 8, 0, time.UTC), "skew"},
+		{"after expiry", time.Date(2017, 3, 5, 23, 56, 8, 0, time.UTC), "expired"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			v := keys.verifier(t, WithClock(func() time.Time { return tc.now }))
+
+			err := v.Verify(newClockReq(), &bytes.Buffer{})
+			if tc.message == "" && err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+
+			if tc.message != "" {
+				if e, ok := err.(*Error); !ok || e.Code != 400 || !strings.Contains(e.Message, tc.message) {
+					t.Errorf("expected %s error, got: %v", tc.message, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithLowercaseHeaderNames(t *testing.T) {
+	keys := newTestKeys()
+
+	canonical := func(opts ...Option) []byte {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signed-Headers", "Host Date Content-Type")
+
+		v := keys.verifier(t, opts...)
+		b, _ := v.canon.canonize(req, bytes.NewBufferString("{}"))
+		return b
+	}
+
+	expected := "put /v1/resources\n" +
+		"host: 127.0.0.1:4567\n" +
+		"date: 2017-03-05T23:53:08Z\n" +
+		"content-type: application/json\n" +
+		"x-signed-headers: host date content-type\n" +
+		"{}"
+	if b := canonical(WithLowercaseHeaderNames()); string(b) != expected {
+		t.Errorf("unexpected canonical form:\n%s", b)
+	}
+
+	if b := canonical(); !bytes.Contains(b, []byte("x-signed-headers: Host Date Content-Type\n")) {
+		t.Errorf("expected signed headers list to be kept by default, got:\n%s", b)
+	}
+}
+
+func TestCanonicalFormHTTPVersions(t *testing.T) {
+	keys := newTestKeys()
+
+	for _, lowercase := range []bool{false, true} {
+		var opts []Option
+		if lowercase {
+			opts = append(opts, WithLowercaseHeaderNames())
+		}
+		v := keys.verifier(t, opts...)
+
+		forms := map[int][]byte{}
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			b, err := v.VerifyReturningCanonical(r, r.Body)
+			if err != nil {
+				t.Errorf("expected request over HTTP/%d to verify, got: %s", r.ProtoMajor, err)
+			}
+			forms[r.ProtoMajor] = b
+		}))
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+		defer srv.Close()
+
+		// The HTTP/1 client does not offer HTTP/2 during the TLS handshake.
+		h1 := srv.Client().Transport.(*http.Transport).Clone()
+		h1.ForceAttemptHTTP2 = false
+		h1.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		h1.TLSClientConfig.NextProtos = []string{"http/1.1"}
+
+		for proto, client := range map[int]*http.Client{1: {Transport: h1}, 2: srv.Client()} {
+			req, _ := http.NewRequest("PUT", srv.URL+"/v1/resources?b=2&a=1", bytes.NewBufferString("{}"))
+			req.Header.Set("Date", "2017-03-05T23:53:08Z")
+			req.Header.Set("content-type", "application/json")
+			req.Header["x-custom"] = []string{" value "}
+			req.Header.Set("X-Signed-Headers", "Host Date Content-Type X-Custom")
+
+			b, _ := v.canon.canonize(req, bytes.NewBufferString("{}"))
+			keys.sign(req, b)
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal("could not send request:", err)
+			}
+			resp.Body.Close()
+
+			if resp.ProtoMajor != proto {
+				t.Fatal("unexpected protocol:", resp.Proto)
+			}
+		}
+
+		if forms[1] == nil || !bytes.Equal(forms[1], forms[2]) {
+			t.Errorf("expected identical canonical forms, got HTTP/1:\n%s\nHTTP/2:\n%s", forms[1], forms[2])
+		}
+	}
+}
+
+func TestWithDecodedPath(t *testing.T) {
+	keys := newTestKeys()
+
+	tcs := []struct {
+		name     string
+		url      string
+		opts     []Option
+		expected string
+	}{
+		{"encoded space", "https://127.0.0.1:4567/v1/my%20resource", nil, "put /v1/my%20resource\n"},
+		{"decoded space", "https://127.0.0.1:4567/v1/my%20resource", []Option{WithDecodedPath()}, "put /v1/my resource\n"},
+		{"encoded slash", "https://127.0.0.1:4567/v1/a%2Fb", nil, "put /v1/a%2Fb\n"},
+		{"decoded slash", "https://127.0.0.1:4567/v1/a%2Fb", []Option{WithDecodedPath()}, "put /v1/a/b\n"},
+		{"unescaped", "https://127.0.0.1:4567/v1/resources", []Option{WithDecodedPath()}, "put /v1/resources\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PUT", tc.url, nil)
+			req.Header.Set("Date", "2017-03-05T23:53:08Z")
+			req.Header.Set("X-Signed-Headers", "host date")
+
+			v := keys.verifier(t, tc.opts...)
+			b, _ := v.canon.canonize(req, &bytes.Buffer{})
+			if !strings.HasPrefix(string(b), tc.expected) {
+				t.Errorf("unexpected canonical form:\n%s", b)
+			}
+
+			keys.sign(req, b)
+			if err := v.Verify(req, &bytes.Buffer{}); err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+		})
+	}
+
+	t.Run("slash collapsing", func(t *testing.T) {
+		signed, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/a/b", nil)
+		signed.Header.Set("Date", "2017-03-05T23:53:08Z")
+		signed.Header.Set("X-Signed-Headers", "host date")
+		b, _ := Canonize(signed, &bytes.Buffer{})
+
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/a%2Fb", nil)
+		req.Header = signed.Header
+		keys.sign(req, b)
+
+		if err := keys.verifier(t, WithDecodedPath()).Verify(req, &bytes.Buffer{}); err != nil {
+			t.Error("expected encoded slash to verify against decoded path, got:", err)
+		}
+
+		if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected encoded slash to fail verification by default")
+		}
+	})
+}
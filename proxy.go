@@ -0,0 +1,14 @@
+package signature
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ReverseProxyMiddleware returns a Handler that verifies each request with v
+// and, if the signature is valid, forwards it with its body intact to target.
+// Invalid requests are responded to directly, and are never forwarded.
+func ReverseProxyMiddleware(v *Verifier, target *url.URL) http.Handler {
+	return v.Wrap(httputil.NewSingleHostReverseProxy(target))
+}
@@ -0,0 +1,60 @@
+package signature
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestReverseProxyMiddleware(t *testing.T) {
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+	verifier, _ := NewVerifier(dummyKey)
+
+	var proxied []string
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		proxied = append(proxied, string(b))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	h := ReverseProxyMiddleware(verifier, target)
+
+	t.Run("Good signature", func(t *testing.T) {
+		proxied = nil
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, newReq())
+
+		if rw.Code != http.StatusNoContent {
+			t.Error("Wrong status code returned:", rw.Code)
+		}
+
+		if len(proxied) != 1 {
+			t.Fatal("Request was not proxied")
+		}
+
+		if len(proxied[0]) != 143 {
+			t.Error("Proxied body was not restored")
+		}
+	})
+
+	t.Run("Bad signature", func(t *testing.T) {
+		proxied = nil
+		req := newReq()
+		req.Header.Set("X-Signature", "bb9iJZVDFrcf8-dw7AsuSCPtdoxoAr61YVWQe-5b9z_YiuQW73wR7RRsDBPnrBMtXIg_h8yKWsr-ZNRgYbM7CA FzNbTkRjAGjkpwHUbAhjvLsIlAlL_M6EUh5E9OVEwXs qGR6iozBfLUCHbRywz1mHDdGYeqZ0JEcseV4KcwjEVeZtQN54odcJ1_QyZkmHacbQeHEai2-Aw9EF8-Ceh09Cg")
+
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if rw.Code != 401 {
+			t.Error("Wrong status code returned:", rw.Code)
+		}
+
+		if len(proxied) != 0 {
+			t.Error("Invalid request was proxied")
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package signature
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter limits the number of attempts per key to limit in each fixed
+// window.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	nextSweep time.Time
+}
+
+type rateBucket struct {
+	count int
+	reset time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, buckets: make(map[string]*rateBucket)}
+}
+
+// allow records an attempt for key at now, returning false if key has already
+// made limit attempts in the current window.
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.After(l.nextSweep) {
+		for k, b := range l.buckets {
+			if !now.Before(b.reset) {
+				delete(l.buckets, k)
+			}
+		}
+		l.nextSweep = now.Add(l.window)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || !now.Before(b.reset) {
+		b = &rateBucket{reset: now.Add(l.window)}
+		l.buckets[key] = b
+	}
+
+	if b.count >= l.limit {
+		return false
+	}
+
+	b.count++
+	return true
+}
+
+// refund removes an attempt recorded for key by allow in the window current at
+// now.
+func (l *rateLimiter) refund(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok && now.Before(b.reset) && b.count > 0 {
+		b.count--
+	}
+}
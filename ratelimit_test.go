@@ -0,0 +1,62 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestWithPerKeyRateLimit(t *testing.T) {
+	keys := newTestKeys()
+	others := &testKeys{
+		master: keys.master,
+		live:   ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize)),
+	}
+	others.endorsement = ed25519.Sign(keys.master, others.live.Public().(ed25519.PublicKey))
+
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	v := keys.verifier(t, WithPerKeyRateLimit(2, time.Minute), WithClock(func() time.Time { return now }))
+	verify := func(k *testKeys, body string) error {
+		req := k.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		return v.Verify(req, bytes.NewBufferString(body))
+	}
+
+	if err := verify(keys, "body"); err != nil {
+		t.Fatal("expected first attempt to verify, got:", err)
+	}
+
+	if err := verify(keys, "other"); err == nil {
+		t.Fatal("expected invalid attempt to fail verification")
+	}
+
+	err := verify(keys, "body")
+	if e, ok := err.(*Error); !ok || e.Code != http.StatusTooManyRequests {
+		t.Fatal("expected third attempt to be rate limited, got:", err)
+	}
+
+	if err := verify(others, "body"); err != nil {
+		t.Error("expected other key to be unaffected, got:", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := verify(keys, "body"); err != nil {
+		t.Error("expected attempt in next window to verify, got:", err)
+	}
+
+	// Requests carrying the live key with a forged endorsement are rejected
+	// without being charged to the key.
+	forged := &testKeys{master: keys.master, live: keys.live, endorsement: bytes.Repeat([]byte{1}, ed25519.SignatureSize)}
+	for i := 0; i < 3; i++ {
+		err := verify(forged, "body")
+		if e, ok := err.(*Error); !ok || e.Code != http.StatusUnauthorized {
+			t.Fatal("expected forged endorsement to be rejected, got:", err)
+		}
+	}
+
+	if err := verify(keys, "body"); err != nil {
+		t.Error("expected forged attempts not to be counted, got:", err)
+	}
+}
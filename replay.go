@@ -0,0 +1,101 @@
+package signature
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// useCounter counts the uses of signature values, allowing each at most limit
+// times.
+type useCounter struct {
+	limit int
+
+	mu        sync.Mutex
+	uses      map[string]*sigUses
+	nextSweep time.Time
+}
+
+type sigUses struct {
+	count   int
+	expires time.Time
+}
+
+func newUseCounter(limit int) *useCounter {
+	return &useCounter{limit: limit, uses: make(map[string]*sigUses)}
+}
+
+// use records a use of sig at now, returning false if it has already been used
+// limit times. A signature dated within window of now may remain acceptable
+// for up to twice window, so its count is kept for that long.
+func (c *useCounter) use(sig string, window time.Duration, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextSweep) {
+		for k, u := range c.uses {
+			if now.After(u.expires) {
+				delete(c.uses, k)
+			}
+		}
+		c.nextSweep = now.Add(window)
+	}
+
+	u, ok := c.uses[sig]
+	if !ok || now.After(u.expires) {
+		u = &sigUses{expires: now.Add(2 * window)}
+		c.uses[sig] = u
+	}
+
+	if u.count >= c.limit {
+		return false
+	}
+
+	u.count++
+	return true
+}
+
+// SignedRequestID returns the value of the request's X-Request-Id header if it
+// is covered by the request's signature, and an empty string otherwise. It
+// does not verify the signature itself; call it only for verified requests.
+func SignedRequestID(req *http.Request) string {
+	return signedValue(req, "X-Request-Id", isSigned)
+}
+
+// SignedNonce returns the value of the request's X-Nonce header if it is
+// covered by the request's signature, and an empty string otherwise. It does
+// not verify the signature itself; call it only for verified requests.
+func SignedNonce(req *http.Request) string {
+	return signedValue(req, "X-Nonce", isSigned)
+}
+
+// signedValue returns the value of the named header of req if signed reports
+// that it is covered by the request's signature, and an empty string
+// otherwise.
+func signedValue(req *http.Request, name string, signed func(http.Header, string) bool) string {
+	if !signed(req.Header, name) {
+		return ""
+	}
+
+	return req.Header.Get(name)
+}
+
+// replayKey returns the key under which uses of the verified request are
+// counted. This is its signed request id when WithRequestIDReplayKey is set,
+// its signed nonce when WithNonceReplayKey is set, and its signature value
+// otherwise.
+func (v *Verifier) replayKey(req *http.Request, sig *Signature) string {
+	if v.requestIDReplayKey {
+		if id := signedValue(req, "X-Request-Id", v.canon.isSigned); id != "" {
+			return "id:" + id
+		}
+	}
+
+	if v.nonceReplayKey {
+		if n := signedValue(req, "X-Nonce", v.canon.isSigned); n != "" {
+			return "nonce:" + n
+		}
+	}
+
+	return sig.Value.String()
+}
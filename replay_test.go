@@ -0,0 +1,272 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithSignatureUseLimit(t *testing.T) {
+	keys := newTestKeys()
+
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	clock := WithClock(func() time.Time { return now })
+
+	t.Run("limit of one", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), clock)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Fatal("expected first use to verify, got:", err)
+		}
+
+		err := v.Verify(req, bytes.NewBufferString("body"))
+		if e, ok := err.(*Error); !ok || e.Code != 401 {
+			t.Error("expected second use to be rejected, got:", err)
+		}
+	})
+
+	t.Run("limit of two", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(2), clock)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+
+		for i := 0; i < 2; i++ {
+			if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+				t.Fatal("expected use to verify, got:", err)
+			}
+		}
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err == nil {
+			t.Error("expected third use to be rejected")
+		}
+	})
+
+	t.Run("invalid uses are not counted", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), clock)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+
+		if err := v.Verify(req, bytes.NewBufferString("other")); err == nil {
+			t.Fatal("expected altered body to fail verification")
+		}
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected first valid use to verify, got:", err)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), clock)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Fatal("expected first use to verify, got:", err)
+		}
+
+		defer func(n time.Time) { now = n }(now)
+		now = now.Add(2*PermittedTimeSkew + time.Second)
+
+		// A request signed at the new time sweeps the expired use.
+		req, _ = http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", now.Format(time.RFC3339))
+		req.Header.Set("X-Signed-Headers", "host date")
+		b, _ := Canonize(req, bytes.NewBufferString("body"))
+		keys.sign(req, b)
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected request after expiry to verify, got:", err)
+		}
+
+		if len(v.uses.uses) != 1 {
+			t.Error("expired uses were not swept")
+		}
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		for _, n := range []int{0, -1} {
+			if _, err := NewVerifier(keys.masterKey(), WithSignatureUseLimit(n)); err == nil {
+				t.Errorf("expected a limit of %d to be rejected", n)
+			}
+		}
+	})
+}
+
+func TestWithRequestIDReplayKey(t *testing.T) {
+	keys := newTestKeys()
+
+	clock := WithClock(func() time.Time { return time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC) })
+
+	newIDReq := func(id, signedHeaders, body string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Request-Id", id)
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, bytes.NewBufferString(body))
+		keys.sign(req, b)
+		return req
+	}
+
+	verify := func(v *Verifier, req *http.Request, body string) error {
+		return v.Verify(req, bytes.NewBufferString(body))
+	}
+
+	t.Run("repeated id", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), WithRequestIDReplayKey(), clock)
+
+		if err := verify(v, newIDReq("abc", "host date x-request-id", "one"), "one"); err != nil {
+			t.Fatal("expected first request to verify, got:", err)
+		}
+
+		err := verify(v, newIDReq("abc", "host date x-request-id", "two"), "two")
+		if e, ok := err.(*Error); !ok || e.Code != 401 {
+			t.Error("expected repeated request id to be rejected, got:", err)
+		}
+
+		if err := verify(v, newIDReq("def", "host date x-request-id", "two"), "two"); err != nil {
+			t.Error("expected new request id to verify, got:", err)
+		}
+	})
+
+	t.Run("unsigned id", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), WithRequestIDReplayKey(), clock)
+
+		for _, body := range []string{"one", "two"} {
+			if err := verify(v, newIDReq("abc", "host date", body), body); err != nil {
+				t.Error("expected unsigned request id to be ignored, got:", err)
+			}
+		}
+	})
+
+	t.Run("structured list", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), WithRequestIDReplayKey(), WithStructuredSignedHeaders(), clock)
+
+		newStructuredReq := func(body string) *http.Request {
+			req := newIDReq("abc", `("host" "date" "x-request-id")`, body)
+			b, _ := v.canon.canonize(req, bytes.NewBufferString(body))
+			keys.sign(req, b)
+			return req
+		}
+
+		if err := verify(v, newStructuredReq("one"), "one"); err != nil {
+			t.Fatal("expected first request to verify, got:", err)
+		}
+
+		err := verify(v, newStructuredReq("two"), "two")
+		if e, ok := err.(*Error); !ok || e.Code != 401 {
+			t.Error("expected repeated request id to be rejected, got:", err)
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), clock)
+
+		for _, body := range []string{"one", "two"} {
+			if err := verify(v, newIDReq("abc", "host date x-request-id", body), body); err != nil {
+				t.Error("expected requests to be counted by signature, got:", err)
+			}
+		}
+	})
+}
+
+func TestSignedRequestID(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+	req.Header.Set("X-Request-Id", "abc")
+
+	req.Header.Set("X-Signed-Headers", "host date")
+	if id := SignedRequestID(req); id != "" {
+		t.Errorf("expected no id for unsigned header, got %q", id)
+	}
+
+	req.Header.Set("X-Signed-Headers", "host date x-request-id")
+	if id := SignedRequestID(req); id != "abc" {
+		t.Errorf("expected id %q, got %q", "abc", id)
+	}
+
+	req.Header.Set("X-Signed-Headers", `("host" "date" "x-request-id")`)
+	if id := SignedRequestID(req); id != "abc" {
+		t.Errorf("expected id %q from structured list, got %q", "abc", id)
+	}
+}
+
+func TestWithNonceReplayKey(t *testing.T) {
+	keys := newTestKeys()
+
+	clock := WithClock(func() time.Time { return time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC) })
+
+	newNonceReq := func(nonce, signedHeaders, body string) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", bytes.NewBufferString(body))
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Nonce", nonce)
+		req.Header.Set("X-Signed-Headers", signedHeaders)
+
+		b, _ := Canonize(req, bytes.NewBufferString(body))
+		keys.sign(req, b)
+		return req
+	}
+
+	t.Run("repeated nonce", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), WithNonceReplayKey(), clock)
+
+		var nonces []string
+		h := v.WrapFunc(func(_ http.ResponseWriter, r *http.Request) {
+			nonces = append(nonces, SignedNonce(r))
+		})
+
+		for _, tc := range []struct {
+			nonce, body string
+			code        int
+		}{
+			{"n1", "one", 200},
+			{"n1", "two", 401},
+			{"n2", "two", 200},
+		} {
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, newNonceReq(tc.nonce, "host date x-nonce", tc.body))
+			if rw.Code != tc.code {
+				t.Errorf("expected nonce %s with body %s to respond %d, got %d", tc.nonce, tc.body, tc.code, rw.Code)
+			}
+		}
+
+		if !reflect.DeepEqual(nonces, []string{"n1", "n2"}) {
+			t.Errorf("unexpected nonces surfaced to handler: %q", nonces)
+		}
+	})
+
+	t.Run("unsigned nonce", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), WithNonceReplayKey(), clock)
+
+		for _, body := range []string{"one", "two"} {
+			if err := v.Verify(newNonceReq("n1", "host date", body), bytes.NewBufferString(body)); err != nil {
+				t.Error("expected unsigned nonce to be ignored, got:", err)
+			}
+		}
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		v := keys.verifier(t, WithSignatureUseLimit(1), clock)
+
+		for _, body := range []string{"one", "two"} {
+			if err := v.Verify(newNonceReq("n1", "host date x-nonce", body), bytes.NewBufferString(body)); err != nil {
+				t.Error("expected requests to be counted by signature, got:", err)
+			}
+		}
+	})
+}
+
+func TestSignedNonce(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+	req.Header.Set("X-Nonce", "abc")
+
+	req.Header.Set("X-Signed-Headers", "host date")
+	if n := SignedNonce(req); n != "" {
+		t.Errorf("expected no nonce for unsigned header, got %q", n)
+	}
+
+	req.Header.Set("X-Signed-Headers", "host date x-nonce")
+	if n := SignedNonce(req); n != "abc" {
+		t.Errorf("expected nonce %q, got %q", "abc", n)
+	}
+}
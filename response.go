@@ -0,0 +1,54 @@
+package signature
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CanonizeResponse builds the canonical representation of the given response,
+// for use in verifying a signature applied to it by the server.
+//
+// The canonical form begins with a "status: CODE" line, followed by the
+// headers listed in the response's X-Signed-Headers header and the body, in
+// the same form used for requests by Canonize. As responses carry no Host
+// header, a signed host is canonicalized with an empty value.
+//
+// The response body is not read directly, instead, body is read, allowing
+// buffering or duplication of the body to be handled outside of this func.
+func CanonizeResponse(resp *http.Response, body io.Reader) ([]byte, error) {
+	var msg bytes.Buffer
+	msg.WriteString("status: ")
+	msg.WriteString(strconv.Itoa(resp.StatusCode))
+	msg.WriteRune('\n')
+
+	(&canonOptions{}).writeHeaders(&msg, resp.Header, "")
+
+	_, err := io.Copy(&msg, body)
+	return msg.Bytes(), err
+}
+
+// VerifyResponse verifies that the given response is signed by a key endorsed
+// by Manifold. It returns an error if the signature is invalid.
+//
+// Responses are signed using the same X-Signature, X-Signed-Headers and Date
+// headers as requests, over the canonical form built by CanonizeResponse.
+func (v *Verifier) VerifyResponse(resp *http.Response, body io.Reader) error {
+	sig, err := v.signatureFromHeader(resp.Header)
+	if err != nil {
+		return err
+	}
+
+	if err := v.checkHeaderDate(resp.Header, time.Time{}, PermittedTimeSkew); err != nil {
+		return err
+	}
+
+	b, err := CanonizeResponse(resp, body)
+	if err != nil {
+		return &Error{Code: 400, Message: "Unable to read response body", category: Malformed}
+	}
+
+	return v.validate(sig, b)
+}
@@ -0,0 +1,87 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func ExampleCanonizeResponse() {
+	body := bytes.NewBufferString("Test body data")
+	resp := &http.Response{StatusCode: 201, Header: http.Header{}}
+	resp.Header.Set("X-Signed-Headers", "date")
+	resp.Header.Set("Date", "2017-03-05T23:53:08Z")
+	b, _ := CanonizeResponse(resp, body)
+
+	fmt.Println()
+	fmt.Println(string(b))
+
+	// Output:
+	// status: 201
+	// date: 2017-03-05T23:53:08Z
+	// x-signed-headers: date
+	// Test body data
+}
+
+func TestVerifyResponse(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	newSignedResp := func(code int, body string) *http.Response {
+		resp := &http.Response{StatusCode: code, Header: http.Header{}}
+		resp.Header.Set("Date", "2017-03-05T23:53:08Z")
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Header.Set("X-Signed-Headers", "date content-type")
+
+		b, err := CanonizeResponse(resp, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal("could not canonize response:", err)
+		}
+		keys.sign(&http.Request{Header: resp.Header}, b)
+
+		return resp
+	}
+
+	for _, code := range []int{200, 500} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			body := `{"message":"ok"}`
+			resp := newSignedResp(code, body)
+			if err := v.VerifyResponse(resp, bytes.NewBufferString(body)); err != nil {
+				t.Error("expected signature to verify, got:", err)
+			}
+		})
+	}
+
+	t.Run("changed status", func(t *testing.T) {
+		body := `{"message":"ok"}`
+		resp := newSignedResp(200, body)
+		resp.StatusCode = 500
+		if err := v.VerifyResponse(resp, bytes.NewBufferString(body)); err == nil {
+			t.Error("expected altered status code to fail verification")
+		}
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		ots := timeSince
+		defer func() { timeSince = ots }()
+		timeSince = func(time.Time) time.Duration { return 30 * time.Minute }
+
+		now := time.Date(2017, 3, 5, 23, 54, 8, 0, time.UTC)
+		v := keys.verifier(t, WithClock(func() time.Time { return now }))
+
+		resp := newSignedResp(200, "")
+		if err := v.VerifyResponse(resp, &bytes.Buffer{}); err != nil {
+			t.Error("expected response to verify at the Verifier's time, got:", err)
+		}
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		resp := newSignedResp(200, "")
+		resp.Header.Del("X-Signature")
+		if err := v.VerifyResponse(resp, &bytes.Buffer{}); err == nil {
+			t.Error("expected unsigned response to fail verification")
+		}
+	})
+}
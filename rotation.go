@@ -0,0 +1,83 @@
+package signature
+
+import (
+	"sync"
+	"time"
+)
+
+// rotation tracks use of the previous master key of a rotating Verifier.
+type rotation struct {
+	previous publicKey
+	observer func(previousUsed bool)
+
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+// NewRotatingVerifier returns a new Verifier for use while rotating master
+// keys, trusting both the current and previous raw base64 URL encoded public
+// keys. The current key is tried first, and each request that needed the
+// previous key is recorded, so that PreviousKeyUnused reports when it is safe
+// to retire.
+//
+// It returns an error if either public key is not valid, as for NewVerifier.
+func NewRotatingVerifier(current, previous string, opts ...Option) (*Verifier, error) {
+	v, err := NewVerifierPool([]string{current, previous}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rotation{previous: v.pool[0], lastUsed: v.clock()}
+	if v.rotation != nil {
+		r.observer = v.rotation.observer
+	}
+	v.rotation = r
+
+	return v, nil
+}
+
+// WithRotationObserver configures a Verifier created by NewRotatingVerifier to
+// call fn for each request it verifies, reporting whether the request needed
+// the previous master key. fn is called synchronously, so it should not block;
+// it may be used to record metrics.
+func WithRotationObserver(fn func(previousUsed bool)) Option {
+	return func(v *Verifier) {
+		if v.rotation == nil {
+			v.rotation = &rotation{}
+		}
+		v.rotation.observer = fn
+	}
+}
+
+// PreviousKeyUnused reports whether no request has needed the previous master
+// key of a Verifier created by NewRotatingVerifier since t, and it has been
+// verifying requests since at least t. If so, the previous key may be retired.
+// It always returns false for other Verifiers.
+func (v *Verifier) PreviousKeyUnused(since time.Time) bool {
+	if v.rotation == nil || v.rotation.previous.isZero() {
+		return false
+	}
+
+	v.rotation.mu.Lock()
+	defer v.rotation.mu.Unlock()
+	return v.rotation.lastUsed.Before(since)
+}
+
+// observe records a request verified at now by a request key endorsed by
+// master.
+func (r *rotation) observe(master publicKey, now time.Time) {
+	if r.previous.isZero() {
+		return
+	}
+
+	used := master.equal(r.previous)
+	if used {
+		r.mu.Lock()
+		r.lastUsed = now
+		r.mu.Unlock()
+	}
+
+	if r.observer != nil {
+		r.observer(used)
+	}
+}
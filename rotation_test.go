@@ -0,0 +1,136 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestNewRotatingVerifier(t *testing.T) {
+	current := newTestKeys()
+	previous := &testKeys{
+		master: ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize)),
+		live:   ed25519.NewKeyFromSeed(bytes.Repeat([]byte{4}, ed25519.SeedSize)),
+	}
+	previous.endorsement = ed25519.Sign(previous.master, previous.live.Public().(ed25519.PublicKey))
+
+	start := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	now := start
+
+	newRotating := func(observed *[]bool) *Verifier {
+		now = start
+		v, err := NewRotatingVerifier(current.masterKey(), previous.masterKey(), WithRotationObserver(func(used bool) {
+			*observed = append(*observed, used)
+		}), WithClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatal("could not create verifier:", err)
+		}
+		return v
+	}
+
+	// verify verifies a request signed by keys, dated at the current time.
+	verify := func(v *Verifier, keys *testKeys) error {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", now.Format(time.RFC3339))
+		req.Header.Set("X-Signed-Headers", "host date")
+		b, _ := Canonize(req, bytes.NewBufferString("body"))
+		keys.sign(req, b)
+
+		return v.Verify(req, bytes.NewBufferString("body"))
+	}
+
+	t.Run("current only", func(t *testing.T) {
+		var observed []bool
+		v := newRotating(&observed)
+
+		now = start.Add(time.Hour)
+		if err := verify(v, current); err != nil {
+			t.Fatal("expected request to verify, got:", err)
+		}
+
+		if len(observed) != 1 || observed[0] {
+			t.Errorf("expected current key use to be observed, got: %v", observed)
+		}
+
+		if !v.PreviousKeyUnused(start.Add(time.Minute)) {
+			t.Error("expected previous key to be unused")
+		}
+
+		if v.PreviousKeyUnused(start.Add(-time.Minute)) {
+			t.Error("expected previous key use before the verifier was created to be unknown")
+		}
+	})
+
+	t.Run("previous needed", func(t *testing.T) {
+		var observed []bool
+		v := newRotating(&observed)
+
+		now = start.Add(time.Hour)
+		if err := verify(v, previous); err != nil {
+			t.Fatal("expected request to verify, got:", err)
+		}
+
+		if len(observed) != 1 || !observed[0] {
+			t.Errorf("expected previous key use to be observed, got: %v", observed)
+		}
+
+		if v.PreviousKeyUnused(start.Add(time.Minute)) {
+			t.Error("expected previous key to have been used")
+		}
+	})
+
+	t.Run("retirement readiness", func(t *testing.T) {
+		var observed []bool
+		v := newRotating(&observed)
+
+		now = start.Add(time.Hour)
+		verify(v, previous) // nolint: errcheck
+
+		// The endorsement is cached, but its use is still recorded.
+		now = start.Add(2 * time.Hour)
+		verify(v, previous) // nolint: errcheck
+
+		now = start.Add(26 * time.Hour)
+		verify(v, current) // nolint: errcheck
+
+		if v.PreviousKeyUnused(now.Add(-25 * time.Hour)) {
+			t.Error("expected previous key to have been used in the last 25 hours")
+		}
+
+		if !v.PreviousKeyUnused(now.Add(-23 * time.Hour)) {
+			t.Error("expected previous key to be unused in the last 23 hours")
+		}
+
+		if len(observed) != 3 {
+			t.Errorf("expected 3 observations, got: %v", observed)
+		}
+	})
+
+	t.Run("unendorsed", func(t *testing.T) {
+		var observed []bool
+		v := newRotating(&observed)
+
+		other := &testKeys{
+			master: ed25519.NewKeyFromSeed(bytes.Repeat([]byte{5}, ed25519.SeedSize)),
+			live:   ed25519.NewKeyFromSeed(bytes.Repeat([]byte{6}, ed25519.SeedSize)),
+		}
+		other.endorsement = ed25519.Sign(other.master, other.live.Public().(ed25519.PublicKey))
+
+		if err := verify(v, other); err == nil {
+			t.Error("expected request to fail verification")
+		}
+
+		if len(observed) != 0 {
+			t.Errorf("expected no observations, got: %v", observed)
+		}
+	})
+
+	t.Run("not rotating", func(t *testing.T) {
+		if current.verifier(t).PreviousKeyUnused(start) {
+			t.Error("expected verifier without a previous key to report it as used")
+		}
+	})
+}
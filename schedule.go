@@ -0,0 +1,200 @@
+package signature
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+// ScheduleKeyPollInterval is how often a ScheduleKeys source fetches its
+// schedule document.
+const ScheduleKeyPollInterval = 5 * time.Minute
+
+// ScheduleKeys is a KeySource backed by a key schedule document, fetched over
+// HTTP. The document lists master keys with the window in which each is
+// valid, and is signed by a pinned bootstrap key:
+//
+//	{
+//	  "version": 2,
+//	  "keys": [
+//	    {"key": "...", "not_before": "2017-03-01T00:00:00Z", "not_after": "2017-04-01T00:00:00Z"}
+//	  ],
+//	  "signature": "..."
+//	}
+//
+// Keys are base64 URL encoded, in the format accepted by NewVerifier. The
+// signature is the base64 URL encoded Ed25519 signature of the decimal
+// version, a newline, and the exact bytes of the keys value. Either window
+// bound may be omitted. A document without a version is version 0, and its
+// signature is of the keys value alone.
+//
+// Only the keys valid at the time of each verification are provided, so keys
+// rotate automatically as their windows pass.
+//
+// A document with a lower version than the active schedule is rejected, so
+// that an older, validly signed schedule can not be replayed to restore
+// retired keys. The publisher must increase the version with each change.
+type ScheduleKeys struct {
+	url       string
+	bootstrap ed25519.PublicKey
+	client    *http.Client
+
+	mu      sync.RWMutex
+	entries []scheduleEntry
+	version uint64
+
+	done chan struct{}
+	once sync.Once
+}
+
+type scheduleDocument struct {
+	Version   uint64          `json:"version"`
+	Keys      json.RawMessage `json:"keys"`
+	Signature *base64.Value   `json:"signature"`
+}
+
+type scheduleEntry struct {
+	Key       *base64.Value `json:"key"`
+	NotBefore time.Time     `json:"not_before"`
+	NotAfter  time.Time     `json:"not_after"`
+}
+
+// ScheduleKeySource returns a ScheduleKeys source that loads its keys from the
+// schedule document at url, which must be signed by bootstrap. It returns an
+// error if the initial load fails.
+//
+// The returned source fetches the document every ScheduleKeyPollInterval until
+// it is closed.
+func ScheduleKeySource(url string, bootstrap ed25519.PublicKey) (*ScheduleKeys, error) {
+	s := &ScheduleKeys{
+		url:       url,
+		bootstrap: bootstrap,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		done:      make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	go s.poll(ScheduleKeyPollInterval)
+	return s, nil
+}
+
+// Keys returns the keys from the most recently loaded schedule that are valid
+// at the current time. A Verifier using the source selects keys by its own
+// clock, as set by WithClock.
+func (s *ScheduleKeys) Keys() []ed25519.PublicKey {
+	return s.keysAt(time.Now())
+}
+
+// keysAt returns the keys from the most recently loaded schedule that are
+// valid at now.
+func (s *ScheduleKeys) keysAt(now time.Time) []ed25519.PublicKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []ed25519.PublicKey
+	for _, e := range s.entries {
+		if !e.NotBefore.IsZero() && now.Before(e.NotBefore) {
+			continue
+		}
+		if !e.NotAfter.IsZero() && now.After(e.NotAfter) {
+			continue
+		}
+
+		keys = append(keys, ed25519.PublicKey(*e.Key))
+	}
+
+	return keys
+}
+
+// Reload fetches and validates the schedule document, replacing the active
+// schedule. If the document can not be fetched, is not validly signed by the
+// bootstrap key, or is older than the active schedule, the active schedule is
+// left unchanged.
+func (s *ScheduleKeys) Reload() error {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected schedule response status: %s", resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var doc scheduleDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	if doc.Signature == nil || !ed25519.Verify(s.bootstrap, doc.signed(), []byte(*doc.Signature)) {
+		return errors.New("schedule is not signed by the bootstrap key")
+	}
+
+	var entries []scheduleEntry
+	if err := json.Unmarshal(doc.Keys, &entries); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Key == nil || len(*e.Key) != ed25519.PublicKeySize {
+			return ErrInvalidPublicKey
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc.Version < s.version {
+		return fmt.Errorf("schedule version %d is older than the active version %d", doc.Version, s.version)
+	}
+
+	s.entries = entries
+	s.version = doc.Version
+	return nil
+}
+
+// signed returns the bytes signed by the document's signature.
+func (d *scheduleDocument) signed() []byte {
+	if d.Version == 0 {
+		return d.Keys
+	}
+
+	return append([]byte(strconv.FormatUint(d.Version, 10)+"\n"), d.Keys...)
+}
+
+// Close stops fetching the schedule. The most recently loaded schedule remains
+// in effect.
+func (s *ScheduleKeys) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *ScheduleKeys) poll(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			// Errors are transient (e.g. an unavailable server); the previous
+			// schedule is kept until the next successful load.
+			s.Reload() // nolint: errcheck
+		case <-s.done:
+			return
+		}
+	}
+}
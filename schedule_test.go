@@ -0,0 +1,177 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+func TestScheduleKeySource(t *testing.T) {
+	bootstrap := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{9}, ed25519.SeedSize))
+	bootstrapPub := bootstrap.Public().(ed25519.PublicKey)
+
+	// Master keys are cut over from old to current at cutover.
+	old := newTestKeys()
+	current := &testKeys{
+		master: ed25519.NewKeyFromSeed(bytes.Repeat([]byte{4}, ed25519.SeedSize)),
+		live:   old.live,
+	}
+	current.endorsement = ed25519.Sign(current.master, current.live.Public().(ed25519.PublicKey))
+	cutover := time.Date(2017, 3, 5, 23, 53, 0, 0, time.UTC)
+
+	keys, _ := json.Marshal([]map[string]interface{}{
+		{"key": base64.New(old.master.Public().(ed25519.PublicKey)), "not_after": cutover},
+		{"key": base64.New(current.master.Public().(ed25519.PublicKey)), "not_before": cutover},
+	})
+
+	document := func(signer ed25519.PrivateKey) []byte {
+		b, _ := json.Marshal(map[string]interface{}{
+			"keys":      json.RawMessage(keys),
+			"signature": base64.New(ed25519.Sign(signer, keys)),
+		})
+		return b
+	}
+
+	versioned := func(version int, keys []byte) []byte {
+		msg := append([]byte(fmt.Sprintf("%d\n", version)), keys...)
+		b, _ := json.Marshal(map[string]interface{}{
+			"version":   version,
+			"keys":      json.RawMessage(keys),
+			"signature": base64.New(ed25519.Sign(bootstrap, msg)),
+		})
+		return b
+	}
+
+	var served []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Write(served)
+	}))
+	defer srv.Close()
+
+	var now time.Time
+	clock := WithClock(func() time.Time { return now })
+
+	t.Run("cutover", func(t *testing.T) {
+		served = document(bootstrap)
+		ks, err := ScheduleKeySource(srv.URL, bootstrapPub)
+		if err != nil {
+			t.Fatal("could not load schedule:", err)
+		}
+		defer ks.Close()
+
+		// The key given to NewVerifier is unrelated to the schedule.
+		unrelated := newTestKeys()
+		unrelated.master = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{5}, ed25519.SeedSize))
+		v := unrelated.verifier(t, WithKeySource(ks), clock)
+
+		tcs := []struct {
+			at    time.Time
+			keys  *testKeys
+			valid bool
+		}{
+			{cutover.Add(-time.Minute), old, true},
+			{cutover.Add(-time.Minute), current, false},
+			{cutover.Add(time.Minute), old, false},
+			{cutover.Add(time.Minute), current, true},
+		}
+
+		for i, tc := range tcs {
+			t.Run(fmt.Sprint(i), func(t *testing.T) {
+				now = tc.at
+				req := tc.keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+
+				err := v.Verify(req, &bytes.Buffer{})
+				if tc.valid && err != nil {
+					t.Error("expected signature to verify, got:", err)
+				}
+				if !tc.valid && err == nil {
+					t.Error("expected signature to fail verification")
+				}
+			})
+		}
+	})
+
+	t.Run("untrusted schedule", func(t *testing.T) {
+		served = document(old.master)
+		if _, err := ScheduleKeySource(srv.URL, bootstrapPub); err == nil {
+			t.Error("expected schedule not signed by bootstrap key to be rejected")
+		}
+	})
+
+	t.Run("reload keeps schedule on failure", func(t *testing.T) {
+		served = document(bootstrap)
+		ks, err := ScheduleKeySource(srv.URL, bootstrapPub)
+		if err != nil {
+			t.Fatal("could not load schedule:", err)
+		}
+		defer ks.Close()
+
+		served = []byte("{}")
+		if err := ks.Reload(); err == nil {
+			t.Error("expected unsigned schedule to be rejected")
+		}
+
+		if len(ks.keysAt(cutover.Add(time.Hour))) != 1 {
+			t.Error("expected previous schedule to remain active")
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		// Version 2 retires the old key, which version 1 still trusts.
+		retired, _ := json.Marshal([]map[string]interface{}{
+			{"key": base64.New(current.master.Public().(ed25519.PublicKey))},
+		})
+
+		served = versioned(2, retired)
+		ks, err := ScheduleKeySource(srv.URL, bootstrapPub)
+		if err != nil {
+			t.Fatal("could not load schedule:", err)
+		}
+		defer ks.Close()
+
+		for _, doc := range [][]byte{versioned(1, keys), document(bootstrap)} {
+			served = doc
+			if err := ks.Reload(); err == nil {
+				t.Error("expected older schedule to be rejected")
+			}
+		}
+
+		currentPub := current.master.Public().(ed25519.PublicKey)
+		if k := ks.keysAt(cutover.Add(-time.Hour)); len(k) != 1 || !bytes.Equal(k[0], currentPub) {
+			t.Error("expected retired key to remain untrusted, got:", k)
+		}
+
+		served = versioned(2, retired)
+		if err := ks.Reload(); err != nil {
+			t.Error("expected same version to be accepted, got:", err)
+		}
+
+		served = versioned(3, keys)
+		if err := ks.Reload(); err != nil {
+			t.Error("expected newer schedule to be accepted, got:", err)
+		}
+		if k := ks.keysAt(cutover.Add(-time.Hour)); len(k) != 1 || bytes.Equal(k[0], currentPub) {
+			t.Error("expected newer schedule to be active, got:", k)
+		}
+	})
+
+	t.Run("version is signed", func(t *testing.T) {
+		doc := versioned(5, keys)
+		var m map[string]interface{}
+		json.Unmarshal(doc, &m) // nolint: errcheck
+		m["version"] = 6
+		served, _ = json.Marshal(m)
+
+		if _, err := ScheduleKeySource(srv.URL, bootstrapPub); err == nil {
+			t.Error("expected altered version to be rejected")
+		}
+	})
+}
@@ -30,16 +30,24 @@ setup.
 package signature
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	stdbase64 "encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ed25519"
@@ -57,10 +65,45 @@ const PermittedTimeSkew = 5 * time.Minute
 // is not valid
 var ErrInvalidPublicKey = errors.New("The provided base64 public key is not valid")
 
+// Sentinel errors identifying common causes of verification failure. Errors
+// returned by a Verifier wrap these where they apply, and can be checked with
+// errors.Is, while remaining *Error values carrying an HTTP status code.
+var (
+	// ErrMissingSignature is the cause of errors for requests that are not
+	// signed.
+	ErrMissingSignature = errors.New("request is not signed")
+
+	// ErrTimeSkew is the cause of errors for requests dated too far from the
+	// current time.
+	ErrTimeSkew = errors.New("request time skew is too great")
+
+	// ErrUnendorsedKey is the cause of errors for requests signed by a live
+	// key that is not endorsed by a trusted master key.
+	ErrUnendorsedKey = errors.New("request public key is not endorsed")
+
+	// ErrBadSignature is the cause of errors for requests whose signature
+	// does not match their canonical form.
+	ErrBadSignature = errors.New("request signature is not valid")
+)
+
 // Error represents an unsuccessful HTTP error response.
 type Error struct {
 	Code    int    `json:"-"` // The HTTP status code.
 	Message string `json:"message"`
+
+	// Extra holds additional fields to include in the JSON representation of
+	// the Error, such as a request id. Extra fields can not replace the
+	// message.
+	Extra map[string]interface{} `json:"-"`
+
+	// field is the JSON key of the message, set by WithErrorJSONField.
+	field string
+
+	// category is the kind of failure, set where the Error is created.
+	category Category
+
+	// cause is the sentinel error describing the failure, if any.
+	cause error
 }
 
 // Error implements the standard error interface for signature Errors.
@@ -68,6 +111,116 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the sentinel error describing the Error, such as ErrTimeSkew,
+// or nil if there is none. This allows the cause to be checked with errors.Is.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// MarshalJSON implements the json.Marshaler interface, merging the Error's
+// Extra fields with its message. The message is keyed as "message" unless
+// configured otherwise by WithErrorJSONField.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(e.Extra)+1)
+	for k, v := range e.Extra {
+		m[k] = v
+	}
+	field := e.field
+	if field == "" {
+		field = "message"
+	}
+	m[field] = e.Message
+
+	return json.Marshal(m)
+}
+
+// Category describes the kind of failure an Error represents.
+type Category int
+
+// The categories of Error.
+const (
+	// Other is the Category of errors not otherwise categorized.
+	Other Category = iota
+	// Malformed is the Category of errors caused by a request that is
+	// missing signature data, or whose signature data can not be read.
+	Malformed
+	// Unauthorized is the Category of errors caused by a request whose
+	// signature is not valid.
+	Unauthorized
+	// TooLarge is the Category of errors caused by a request that is too
+	// large to verify.
+	TooLarge
+	// TimeWindow is the Category of errors caused by a well formed request
+	// presented outside of its permitted time window, such as one with too
+	// great a time skew, or whose signature has expired.
+	TimeWindow
+	// ContentType is the Category of errors caused by a request whose
+	// Content-Type is not allowed.
+	ContentType
+	// Forbidden is the Category of errors caused by a request rejected by the
+	// Verifier's policy regardless of its signature, such as one from outside
+	// of the allowed networks, or over too old a TLS version.
+	Forbidden
+	// RateLimited is the Category of errors caused by a request whose public
+	// key has exceeded its rate limit.
+	RateLimited
+	// Timeout is the Category of errors caused by a verification that did not
+	// complete before its timeout, or before its context was done.
+	Timeout
+)
+
+func (c Category) String() string {
+	switch c {
+	case Malformed:
+		return "malformed"
+	case Unauthorized:
+		return "unauthorized"
+	case TooLarge:
+		return "too large"
+	case TimeWindow:
+		return "time window"
+	case ContentType:
+		return "content type"
+	case Forbidden:
+		return "forbidden"
+	case RateLimited:
+		return "rate limited"
+	case Timeout:
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+// Category returns the Category of the Error. The category is set where the
+// Error is created, and does not depend on its HTTP status code, which may be
+// configured, as by WithSemanticErrorCode. Errors created outside of this
+// package, which have no category, are categorized by their code.
+func (e *Error) Category() Category {
+	if e.category != Other {
+		return e.category
+	}
+
+	switch e.Code {
+	case http.StatusBadRequest:
+		return Malformed
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusRequestTimeout:
+		return Timeout
+	case http.StatusRequestEntityTooLarge:
+		return TooLarge
+	case http.StatusUnsupportedMediaType:
+		return ContentType
+	case http.StatusTooManyRequests:
+		return RateLimited
+	default:
+		return Other
+	}
+}
+
 // Respond writes the Error to the provided ResponseWriter as JSON, in the
 // format expected by Manifold for errors.
 func (e *Error) Respond(rw http.ResponseWriter) {
@@ -90,6 +243,10 @@ type Signature struct {
 	Value       *base64.Value
 	PublicKey   *base64.Value
 	Endorsement *base64.Value
+
+	// Algorithm is the signature scheme of the chain. The zero value is
+	// Ed25519.
+	Algorithm Algorithm
 }
 
 // String returns the string representatino of a Signature
@@ -99,79 +256,264 @@ func (s *Signature) String() string {
 
 // Validate returns an error if the given byte slice does not match this
 // signature
+//
+// masterPubKey must be a key of the signature's Algorithm; for Ed448 it holds
+// the raw Ed448 public key.
 func (s *Signature) Validate(masterPubKey ed25519.PublicKey, b []byte) error {
-	if !ed25519.Verify(masterPubKey, []byte(*s.PublicKey), []byte(*s.Endorsement)) {
-		return &Error{Code: 401, Message: "Request Public Key was not endorsed by Manifold"}
+	if !s.Algorithm.verify(masterPubKey, []byte(*s.PublicKey), []byte(*s.Endorsement)) {
+		return &Error{Code: 401, Message: "Request Public Key was not endorsed by Manifold", category: Unauthorized, cause: ErrUnendorsedKey}
 	}
 
-	livePubKey := ed25519.PublicKey([]byte(*s.PublicKey))
-	if !ed25519.Verify(livePubKey, b, []byte(*s.Value)) {
-		return &Error{Code: 401, Message: "Request was not signed by included Public Key"}
+	if !s.Algorithm.verify([]byte(*s.PublicKey), b, []byte(*s.Value)) {
+		return &Error{Code: 401, Message: "Request was not signed by included Public Key", category: Unauthorized, cause: ErrBadSignature}
 	}
 
 	return nil
 }
 
-// ParseSignature parses the given string and returns a Signature struct
-func ParseSignature(value string) (*Signature, error) {
-	sigerr := &Error{Code: 400, Message: "Could not parse Signature chain"}
-	parts := strings.Split(value, " ")
-	if len(parts) != 3 {
-		return nil, sigerr
+// SignedBy reports whether the signature carries the given live public key,
+// and its value is a valid signature of b by that key. Unlike Validate, it
+// does not check the key's endorsement, so it should be used alongside
+// verification, to attribute a request to a known live key.
+func (s *Signature) SignedBy(livePub ed25519.PublicKey, b []byte) bool {
+	if !bytes.Equal(livePub, []byte(*s.PublicKey)) {
+		return false
 	}
 
-	v, err := base64.NewFromString(parts[0])
+	return s.Algorithm.verify(livePub, b, []byte(*s.Value))
+}
+
+// ParseAndValidateChain parses the X-Signature header value header, and
+// validates that its live public key is endorsed by master, returning the live
+// key. It does not check the signature value, allowing callers with custom
+// body handling to verify it themselves.
+func ParseAndValidateChain(header string, master ed25519.PublicKey) (ed25519.PublicKey, error) {
+	sig, err := ParseSignature(header)
 	if err != nil {
 		return nil, err
 	}
 
-	k, err := base64.NewFromString(parts[1])
-	if err != nil {
-		return nil, err
+	if len(*sig.PublicKey) != ed25519.PublicKeySize {
+		return nil, &Error{Code: 400, Message: "Request Public Key is not a valid Ed25519 key", category: Malformed}
 	}
 
-	e, err := base64.NewFromString(parts[2])
-	if err != nil {
-		return nil, err
+	if !Ed25519.verify(master, []byte(*sig.PublicKey), []byte(*sig.Endorsement)) {
+		return nil, &Error{Code: 401, Message: "Request Public Key was not endorsed by Manifold", category: Unauthorized, cause: ErrUnendorsedKey}
+	}
+
+	return ed25519.PublicKey(*sig.PublicKey), nil
+}
+
+// errComponentLength is the cause of errors for signatures whose decoded
+// components have the wrong length for their algorithm.
+var errComponentLength = errors.New("signature component has an invalid length")
+
+// ParseSignature parses the given string and returns a Signature struct
+//
+// It returns a 400 Error if the decoded public key and value do not have the
+// lengths of a key and signature of a supported Algorithm, such as 32 and 64
+// bytes for Ed25519. The endorsement is checked by Verify, as it may hold a
+// certificate.
+//
+// The three components may be delimited by single spaces, or by line breaks,
+// as emitted by signers that fold the header value across lines. Whitespace
+// surrounding each line is ignored.
+func ParseSignature(value string) (*Signature, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		value = unfoldSignature(value)
+	}
+
+	var parts [3]string
+	rest := value
+	for i := range parts[:2] {
+		j := strings.IndexByte(rest, ' ')
+		if j < 0 {
+			return nil, &Error{Code: 400, Message: "Could not parse Signature chain", category: Malformed}
+		}
+		parts[i], rest = rest[:j], rest[j+1:]
+	}
+	if strings.IndexByte(rest, ' ') >= 0 {
+		return nil, &Error{Code: 400, Message: "Could not parse Signature chain", category: Malformed}
+	}
+	parts[2] = rest
+
+	// Decode all three components into a single allocation, which also holds
+	// a copy of the encoded value to decode from. Each component's slice is
+	// capped at its own length, so appending to one can never overwrite
+	// another.
+	enc := stdbase64.RawURLEncoding
+	buf := make([]byte, len(value)+enc.DecodedLen(len(value)))
+	src, dst := buf[:len(value)], buf[len(value):]
+	copy(src, value)
+
+	sv := &struct {
+		sig  Signature
+		vals [3]base64.Value
+	}{}
+
+	off := 0
+	for i, p := range parts {
+		n, err := enc.Decode(dst, src[off:off+len(p)])
+		if err != nil {
+			return nil, err
+		}
+
+		sv.vals[i] = base64.Value(dst[:n:n])
+		dst = dst[n:]
+		off += len(p) + 1
+	}
+
+	sv.sig = Signature{
+		Value:       &sv.vals[0],
+		PublicKey:   &sv.vals[1],
+		Endorsement: &sv.vals[2],
+	}
+	if !Ed25519.validLengths(&sv.sig) && !Ed448.validLengths(&sv.sig) {
+		return nil, componentLengthError()
+	}
+
+	return &sv.sig, nil
+}
+
+// componentLengthError returns the Error for a signature whose components
+// have the wrong lengths.
+func componentLengthError() *Error {
+	return &Error{Code: 400, Message: "X-Signature components have invalid lengths", category: Malformed, cause: errComponentLength}
+}
+
+// unfoldSignature joins the lines of a signature folded across lines with
+// single spaces.
+func unfoldSignature(value string) string {
+	lines := strings.Split(strings.TrimSpace(strings.Replace(value, "\r\n", "\n", -1)), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
 	}
 
-	return &Signature{
-		Value:       v,
-		PublicKey:   k,
-		Endorsement: e,
-	}, nil
+	return strings.Join(lines, " ")
 }
 
 // Canonize builds the canonical representation of the given request, for use in
 // verifying the Manifold request signature applied to it.
 // The request body is not read directly, instead, body is read, allowing
 // buffering or duplication of the body to be handled outside of this func.
+//
+// Query params are sorted as sent, including their name, '=' and value, so
+// params with the same name are ordered by value: "?b=2&a=1&a=0" is
+// canonicalized as "?a=0&a=1&b=2". Params are not decoded, unless a Verifier
+// is configured with WithDecodedQuery.
+//
+// Names in X-Signed-Headers are matched without regard to case, and a header
+// listed more than once is included only at its first occurrence.
+//
+// Signed headers, including Date, appear in the canonical form with their
+// literal values. A Date sent with a UTC offset is not normalized to UTC, so
+// signers must sign the exact string they send.
 func Canonize(req *http.Request, body io.Reader) ([]byte, error) {
+	return (&canonOptions{}).canonize(req, body)
+}
+
+// canonOptions holds the optional tweaks to the canonical form that a
+// Verifier may be configured with. The zero value produces the canonical form
+// described by Canonize.
+type canonOptions struct {
+	base64Body      bool
+	plusAsSpace     bool
+	collapseSlashes bool
+	decodedPath     bool
+	sortedHeaders   bool
+	lowercaseNames  bool
+	stripBOM        bool
+	cookies         bool
+	rawPathHeader   string
+	bodyFrame       func([]byte) ([]byte, error)
+
+	hostNormalization  bool
+	signedQueryParams  map[string]bool
+	absoluteTarget     bool
+	bodyDigest         bool
+	preSortedQuery     bool
+	decodedQuery       bool
+	structuredHeaders  bool
+	lengthPrefixedBody bool
+	bodyTransforms     []func([]byte) ([]byte, error)
+	multipartBoundary  bool
+}
+
+func (o *canonOptions) canonize(req *http.Request, body io.Reader) ([]byte, error) {
 	var msg bytes.Buffer
-	// Begin writing the target of the signature.
-	// start with the request target:
-	//     lower(METHOD) <space > PATH <'?'> canonical(QUERY) <newline>
-	// where canonical(QUERY) is the query params, lexicographically sorted
-	// in ascending order (including param name, = sign, and value),
-	// and delimited by an '&'.
-	// If no query params are set, the '?' is omitted.
-	method := req.Method
-	if method == "" {
-		method = http.MethodGet
+	o.writeHead(&msg, req)
+
+	// Finally, include the contents of the request body, if it is non-zero in
+	// length.
+	if o.base64Body {
+		b, err := decodeBase64Body(body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
 	}
-	msg.WriteString(strings.ToLower(method))
-	msg.WriteRune(' ')
-	msg.WriteString(req.URL.EscapedPath())
 
-	if len(req.URL.RawQuery) > 0 {
-		msg.WriteRune('?')
+	if o.bodyFrame != nil {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
 
-		parts := strings.Split(req.URL.RawQuery, "&")
-		sort.Strings(parts)
-		msg.WriteString(strings.Join(parts, "&"))
+		if b, err = o.bodyFrame(b); err != nil {
+			return nil, &Error{Code: 400, Message: "Could not remove framing from request body", category: Malformed}
+		}
+		body = bytes.NewReader(b)
 	}
 
-	msg.WriteRune('\n')
+	if o.stripBOM {
+		body = stripBOM(body)
+	}
+
+	if boundary := multipartBoundary(req.Header.Get("Content-Type")); o.multipartBoundary && boundary != "" {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(normalizeBoundary(b, boundary))
+	}
+
+	if len(o.bodyTransforms) > 0 {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fn := range o.bodyTransforms {
+			if b, err = fn(b); err != nil {
+				return nil, &Error{Code: 400, Message: "Could not transform request body", category: Malformed}
+			}
+		}
+		body = bytes.NewReader(b)
+	}
+
+	if o.bodyDigest && o.isSigned(req.Header, "Digest") {
+		return msg.Bytes(), checkDigest(req.Header.Get("Digest"), body)
+	}
+
+	if o.lengthPrefixedBody {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.WriteString("body: ")
+		msg.WriteString(strconv.Itoa(len(b)))
+		msg.WriteRune('\n')
+		body = bytes.NewReader(b)
+	}
+
+	_, err := io.Copy(&msg, body)
+	return msg.Bytes(), err
+}
+
+// writeHead writes the canonical form of req, without its body, to msg.
+func (o *canonOptions) writeHead(msg *bytes.Buffer, req *http.Request) {
+	o.writeTarget(msg, req)
 
 	// Next, add all headers. These are the headers listed in the
 	// X-Signed-Headers  header, in the order they are listed, followed by
@@ -187,156 +529,1494 @@ func Canonize(req *http.Request, body io.Reader) ([]byte, error) {
 	// lowercased, and delimited by a space. Only one occurrence of
 	// X-Signed-Headers should exist on a request. If more than one exists,
 	// The first is used.
-	headers := strings.Split(req.Header.Get("x-signed-headers"), " ")
-	headers = append(headers, "x-signed-headers")
-	for _, h := range headers {
-		ch := http.CanonicalHeaderKey(h)
+	//
+	// Names in the list are matched without regard to case. A name listed more
+	// than once, such as "date date" or "Content-Type content-type", has its
+	// header written only once, at its first occurrence. The X-Signed-Headers
+	// value itself is written as sent.
+	o.writeHeaders(msg, req.Header, o.host(req))
+}
 
-		rhvs := req.Header[ch]
-		if ch == "Host" {
-			host := req.Host
-			if host == "" {
-				host = req.URL.Host
-			}
+// streamsBody reports whether the body is included in the canonical form as it
+// is read, following the head written by writeHead, so that it can be verified
+// without being held in memory.
+func (o *canonOptions) streamsBody() bool {
+	return !o.base64Body && o.bodyFrame == nil && !o.multipartBoundary && len(o.bodyTransforms) == 0 &&
+		!o.bodyDigest && !o.lengthPrefixedBody
+}
 
-			rhvs = []string{host}
+// streamedBody returns a reader yielding the canonical form of body, for
+// options where streamsBody reports true.
+func (o *canonOptions) streamedBody(body io.Reader) io.Reader {
+	if o.stripBOM {
+		return stripBOM(body)
+	}
+
+	return body
+}
+
+// writeTarget writes the canonical request target line of req to msg.
+func (o *canonOptions) writeTarget(msg *bytes.Buffer, req *http.Request) {
+	// Begin writing the target of the signature.
+	// start with the request target:
+	//     lower(METHOD) <space > PATH <'?'> canonical(QUERY) <newline>
+	// where canonical(QUERY) is the query params, lexicographically sorted
+	// in ascending order (including param name, = sign, and value),
+	// and delimited by an '&'.
+	// If no query params are set, the '?' is omitted. With WithPreSortedQuery,
+	// the query params are left in the order sent, and with WithDecodedQuery
+	// they are decoded, sorted by name and then value, and re-encoded.
+	// With WithAbsoluteTarget, PATH is preceded by lower(SCHEME) "://" HOST.
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	msg.WriteString(strings.ToLower(method))
+	msg.WriteRune(' ')
+	if o.absoluteTarget {
+		msg.WriteString(scheme(req))
+		msg.WriteString("://")
+		msg.WriteString(o.host(req))
+	}
+	path := req.URL.EscapedPath()
+	if o.decodedPath {
+		path = req.URL.Path
+	}
+	if o.rawPathHeader != "" {
+		if p := req.Header.Get(o.rawPathHeader); p != "" {
+			path = p
 		}
+	}
+	if o.collapseSlashes {
+		path = collapseSlashes(path)
+	}
+	msg.WriteString(path)
 
-		msg.WriteString(strings.ToLower(h))
-		msg.WriteString(": ")
+	var parts []string
+	if len(req.URL.RawQuery) > 0 {
+		parts = strings.Split(req.URL.RawQuery, "&")
+	}
+	if o.signedQueryParams != nil {
+		parts = o.filterQuery(parts)
+	}
 
-		var hvs []string
-		for _, hv := range rhvs {
-			hvs = append(hvs, strings.TrimSpace(hv))
+	if len(parts) > 0 {
+		msg.WriteRune('?')
+
+		if o.plusAsSpace {
+			for i, p := range parts {
+				parts[i] = strings.Replace(p, "+", "%20", -1)
+			}
 		}
-		msg.WriteString(strings.Join(hvs, ", "))
-		msg.WriteRune('\n')
+		switch {
+		case o.preSortedQuery:
+		case o.decodedQuery:
+			parts = canonicalQuery(parts)
+		default:
+			sort.Strings(parts)
+		}
+		msg.WriteString(strings.Join(parts, "&"))
 	}
 
-	// Finally, include the contents of the request body, if it is non-zero in
-	// length.
-	_, err := io.Copy(&msg, body)
-	return msg.Bytes(), err
+	msg.WriteRune('\n')
 }
 
-// Verifier verifies that HTTP requests are signed by Manifold
-type Verifier struct {
-	pk ed25519.PublicKey
-}
+// host returns the host of req, as used in its canonical form.
+func (o *canonOptions) host(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if o.hostNormalization {
+		host = trimHostDot(host)
+	}
 
-// NewVerifier returns a new Verifier, configured with the provided raw base64
-// URL encoded public key.
-//
-// It returns an error if the given public key is not a valid base64 URL encoded
-// value, or if it is not a valid Ed25519 public key.
-func NewVerifier(publicKey string) (*Verifier, error) {
-	// be lenient of different base64 formats
-	spk := strings.Replace(publicKey, "+", "-", -1)
-	spk = strings.Replace(spk, "/", "_", -1)
-	spk = strings.TrimRight(spk, "=")
+	return host
+}
 
-	pkv, err := base64.NewFromString(spk)
-	if err != nil || len(*pkv) != ed25519.PublicKeySize {
-		return nil, ErrInvalidPublicKey
+// scheme returns the scheme of req's URL, or if it is not set, as it is not
+// for server requests, "https" for requests received over TLS and "http"
+// otherwise.
+func scheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
 	}
 
-	return &Verifier{pk: ed25519.PublicKey((*pkv)[:ed25519.PublicKeySize])}, nil
+	return "http"
 }
 
-// timeSince is replaced during testing
-var timeSince = func(rt time.Time) time.Duration {
-	return time.Since(rt)
+// queryParam is a query parameter, decoded for canonicalization.
+type queryParam struct {
+	name, value string
+	hasValue    bool
+
+	// raw is set to the param as sent, if it could not be decoded.
+	raw string
 }
 
-// Verify verifies that the given request is signed by Manifold. It returns an
-// error if the signature is invalid.
-// The request body is not read directly, instead, body is read, allowing
-// buffering or duplication of the body to be handled outside of this method.
-func (v *Verifier) Verify(req *http.Request, body io.Reader) error {
-	sigHeader := req.Header.Get("X-Signature")
-	if sigHeader == "" {
-		return &Error{Code: 400, Message: "Missing X-Signature header"}
+// canonicalQuery returns the query params in parts, decoded, sorted by name
+// and then value, and re-encoded, so that equivalent encodings, such as "+"
+// and "%20", share a canonical form. Spaces are encoded as "%20". Params that
+// can not be decoded are sorted by their name, and included as sent.
+func canonicalQuery(parts []string) []string {
+	params := make([]queryParam, len(parts))
+	for i, p := range parts {
+		params[i] = decodeQueryParam(p)
 	}
 
-	sig, err := ParseSignature(sigHeader)
-	if err != nil {
-		return &Error{Code: 400, Message: "Could not parse X-Signature header"}
-	}
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
 
-	headerList := req.Header.Get("X-Signed-Headers")
-	if headerList == "" {
-		return &Error{Code: 400, Message: "Missing X-Signed-Headers header"}
+	canonical := make([]string, len(params))
+	for i, p := range params {
+		canonical[i] = p.encode()
 	}
 
-	rt, err := time.Parse(time.RFC3339, req.Header.Get("Date"))
-	if err != nil {
-		return &Error{Code: 400, Message: "Unable to read request date"}
+	return canonical
+}
+
+// decodeQueryParam decodes the query param p, written as name=value.
+func decodeQueryParam(p string) queryParam {
+	name, value, hasValue := p, "", false
+	if i := strings.IndexByte(p, '='); i >= 0 {
+		name, value, hasValue = p[:i], p[i+1:], true
 	}
 
-	delta := timeSince(rt)
-	if delta < 0 {
-		delta = -delta
+	dn, nerr := url.QueryUnescape(name)
+	dv, verr := url.QueryUnescape(value)
+	if nerr != nil || verr != nil {
+		return queryParam{name: name, value: value, raw: p}
 	}
 
-	if delta > PermittedTimeSkew {
-		return &Error{Code: 400, Message: "Request time skew is too great"}
+	return queryParam{name: dn, value: dv, hasValue: hasValue}
+}
+
+// encode returns the query param in canonical form.
+func (p queryParam) encode() string {
+	if p.raw != "" {
+		return p.raw
 	}
 
-	b, err := Canonize(req, body)
-	if err != nil {
-		return &Error{Code: 400, Message: "Unable to read request body"}
+	name := queryEscape(p.name)
+	if !p.hasValue {
+		return name
 	}
 
-	return sig.Validate(v.pk, b)
+	return name + "=" + queryEscape(p.value)
 }
 
-// Wrap wraps the provided Handler, returning a new Handler that will verify
-// the request before passing it through to the Handler. If the request is
-// invalid,  Wrap will respond appropriately through the RequestWriter
-func (v *Verifier) Wrap(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		v.Negroni().ServeHTTP(rw, req, handler.ServeHTTP)
-	})
+// queryEscape escapes s for use in a canonical query, encoding spaces as
+// "%20".
+func queryEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
 }
 
-// WrapFunc is the HandlerFunc version of Wrap.
-func (v *Verifier) WrapFunc(handler http.HandlerFunc) http.Handler {
-	return v.Wrap(http.HandlerFunc(handler))
-}
+// filterQuery returns the query parameters in parts whose names are listed in
+// o.signedQueryParams. Names are compared after unescaping.
+func (o *canonOptions) filterQuery(parts []string) []string {
+	var signed []string
+	for _, p := range parts {
+		name := p
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			name = p[:i]
+		}
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
 
-// Middleware implements the Negroni middleware interface for funcs
-type Middleware func(http.ResponseWriter, *http.Request, http.HandlerFunc)
+		if o.signedQueryParams[name] {
+			signed = append(signed, p)
+		}
+	}
 
-func (m Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	m(rw, r, next)
+	return signed
 }
 
-// Negroni returns a Negroni compatible middleware for verifying requests.
-// This middleware behaves like Wrap; it will not pass through to the next
-// Handler in the chain if the request does not have a valid signature.
-func (v *Verifier) Negroni() Middleware {
-	return Middleware(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-		b := &bytes.Buffer{}
-		_, err := b.ReadFrom(req.Body)
-		if err != nil {
-			e := &Error{400, "Could not ready body from request"}
-			e.Respond(rw)
-			return
+// writeHeaders writes the headers listed in the X-Signed-Headers header in
+// header to msg, in canonical form. host is used as the value of the Host
+// header.
+func (o *canonOptions) writeHeaders(msg *bytes.Buffer, header http.Header, host string) {
+	signed := o.signedHeaders(header)
+	headers := append(signed, "x-signed-headers")
+	for _, h := range headers {
+		ch := http.CanonicalHeaderKey(h)
+
+		// Headers set directly on the map, rather than through Set or Add,
+		// may be keyed by their lowercase HTTP/2 names.
+		rhvs, ok := header[ch]
+		if !ok {
+			rhvs = header[strings.ToLower(h)]
 		}
 
-		defer req.Body.Close()
+		switch {
+		case ch == "Host":
+			rhvs = []string{host}
+		case ch == "X-Signed-Headers" && o.lowercaseNames:
+			rhvs = []string{strings.ToLower(strings.Join(signed, " "))}
+		case ch == "X-Signed-Headers" && o.sortedHeaders:
+			rhvs = []string{strings.Join(signed, " ")}
+		case ch == "Cookie" && o.cookies:
+			rhvs = []string{canonicalCookies(rhvs)}
+		case ch == "Content-Type" && o.multipartBoundary && len(rhvs) == 1:
+			rhvs = []string{canonicalContentType(rhvs[0])}
+		}
 
-		req.Body = ioutil.NopCloser(bytes.NewReader(b.Bytes()))
-		err = v.Verify(req, b)
-		if e, ok := err.(*Error); ok {
-			e.Respond(rw)
-			return
+		msg.WriteString(strings.ToLower(h))
+		msg.WriteString(": ")
+
+		var hvs []string
+		for _, hv := range rhvs {
+			hvs = append(hvs, strings.TrimSpace(hv))
 		}
+		msg.WriteString(strings.Join(hvs, ", "))
+		msg.WriteRune('\n')
+	}
+}
 
-		if err != nil {
-			e := &Error{401, "Could not validate authenticity of the request"}
-			e.Respond(rw)
-			return
+// signedHeaders returns the headers listed in the X-Signed-Headers header in
+// header, in the order they are canonicalized. Names listed more than once,
+// in any case, are returned only at their first occurrence, with the case it
+// was listed in.
+func (o *canonOptions) signedHeaders(header http.Header) []string {
+	var signed []string
+	if o.structuredHeaders {
+		signed, _ = parseStructuredList(header.Get("x-signed-headers"))
+	} else {
+		signed = strings.Split(header.Get("x-signed-headers"), " ")
+	}
+
+	seen := make(map[string]bool, len(signed))
+	unique := signed[:0]
+	for _, name := range signed {
+		lower := strings.ToLower(name)
+		if name != "" && seen[lower] {
+			continue
+		}
+		seen[lower] = true
+		unique = append(unique, name)
+	}
+	signed = unique
+
+	if o.sortedHeaders {
+		sort.Strings(signed)
+	}
+
+	return signed
+}
+
+// listsHeaders reports whether signed, as returned by signedHeaders, names any
+// header. An empty X-Signed-Headers header is split into a single empty name,
+// which is kept so that the canonical form is unchanged.
+func listsHeaders(signed []string) bool {
+	for _, name := range signed {
+		if name != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trimHostDot removes a single trailing dot from the name in host, which may
+// include a port.
+func trimHostDot(host string) string {
+	name, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		name, port = host[:i], host[i:]
+	}
+
+	return strings.TrimSuffix(name, ".") + port
+}
+
+// canonicalCookies joins the name=value pairs from the given Cookie header
+// values, sorted, and delimited by "; ".
+func canonicalCookies(values []string) string {
+	var pairs []string
+	for _, v := range values {
+		for _, p := range strings.Split(v, ";") {
+			if p = strings.TrimSpace(p); p != "" {
+				pairs = append(pairs, p)
+			}
+		}
+	}
+
+	sort.Strings(pairs)
+	return strings.Join(pairs, "; ")
+}
+
+// collapseSlashes replaces each run of repeated slashes in path with a single
+// slash.
+func collapseSlashes(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && i > 0 && path[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
+
+// utf8BOM is the UTF-8 encoded byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// stripBOM returns a reader that reads body, omitting a leading UTF-8 byte
+// order mark if there is one.
+func stripBOM(body io.Reader) io.Reader {
+	br := bufio.NewReader(body)
+	if b, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(b, utf8BOM) {
+		br.Discard(len(utf8BOM)) // nolint: errcheck
+	}
+
+	return br
+}
+
+// decodeBase64Body reads body in full and decodes it from base64url. Padding
+// is optional.
+func decodeBase64Body(body io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := base64.NewFromString(strings.TrimRight(string(b), "="))
+	if err != nil {
+		return nil, &Error{Code: 400, Message: "Could not decode base64 request body", category: Malformed}
+	}
+
+	return []byte(*v), nil
+}
+
+// Verifier verifies that HTTP requests are signed by Manifold
+//
+// A Verifier is safe for concurrent use, and should be created once and shared
+// by all requests, rather than created per request or pooled. State such as
+// its endorsement cache, signature use counts and rate limits is kept per
+// Verifier, so use limits and rate limits only apply across requests verified
+// by the same Verifier.
+type Verifier struct {
+	alg          Algorithm
+	pk           publicKey
+	pool         []publicKey
+	rotation     *rotation
+	keys         KeySource
+	canon        canonOptions
+	endorsements *endorsementCache
+
+	bodyTap         chan<- []byte
+	certEndorsement bool
+	minTLSVersion   uint16
+	skewByPath      map[string]time.Duration
+	unsignedHandler http.Handler
+	uses            *useCounter
+	contentTypes    []string
+	splitSignature  bool
+	spillThreshold  int64
+	spillDir        string
+
+	authorizationHeader bool
+
+	allowEmptySignedHeaders bool
+	deadlineFromExpiry      bool
+	requestIDReplayKey      bool
+	nonceReplayKey          bool
+	errorField              string
+	errorResponder          func(http.ResponseWriter, *Error)
+	preVerify               func(*http.Request) error
+	bodyFooterMarker        []byte
+	receiptTime             func(*http.Request) time.Time
+	auditLog                func(AuditEntry)
+	criticalHeaders         []string
+	rateLimit               *rateLimiter
+	verifyTimeout           time.Duration
+	combinedSignature       bool
+	semanticCode            int
+	strictHeaderCase        bool
+	optionalHeaders         map[string]bool
+	maxSkewCeiling          time.Duration
+	maxBodySize             int64
+	allowedNets             []*net.IPNet
+	forwardedFor            bool
+	now                     func() time.Time
+
+	// optionErr is set by an Option given invalid arguments, and returned by
+	// the Verifier's constructor.
+	optionErr error
+}
+
+// NewVerifier returns a new Verifier, configured with the provided raw base64
+// URL encoded public key.
+//
+// It returns an error if the given public key is not a valid base64 URL encoded
+// value, or if it is not a valid Ed25519 public key.
+//
+// Any provided Options are applied to the Verifier in order, and any error
+// from an Option given invalid arguments is returned.
+func NewVerifier(publicKey string, opts ...Option) (*Verifier, error) {
+	return NewVerifierWithAlgorithm(Ed25519, publicKey, opts...)
+}
+
+// NewVerifierWithAlgorithm returns a new Verifier for signatures using the
+// given Algorithm, configured with the provided raw base64 URL encoded public
+// key of that algorithm. The Verifier only accepts requests whose
+// X-Signature-Algorithm header names the same algorithm.
+func NewVerifierWithAlgorithm(alg Algorithm, publicKey string, opts ...Option) (*Verifier, error) {
+	pk, err := parsePublicKey(alg, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Verifier{
+		alg:          alg,
+		pk:           pk,
+		endorsements: newEndorsementCache(endorsementCacheSize, endorsementCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.optionErr != nil {
+		return nil, v.optionErr
+	}
+
+	return v, nil
+}
+
+// NewVerifierPool returns a new Verifier that trusts each of the provided raw
+// base64 URL encoded public keys, accepting requests endorsed by any of them.
+// This allows both the old and new master keys to be trusted while a key is
+// rotated.
+//
+// It returns ErrInvalidPublicKey if no keys are given, or if any is not valid,
+// as for NewVerifier.
+func NewVerifierPool(publicKeys []string, opts ...Option) (*Verifier, error) {
+	if len(publicKeys) == 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	var pool []publicKey
+	for _, k := range publicKeys[1:] {
+		pk, err := parsePublicKey(Ed25519, k)
+		if err != nil {
+			return nil, err
+		}
+		pool = append(pool, pk)
+	}
+
+	v, err := NewVerifier(publicKeys[0], opts...)
+	if err != nil {
+		return nil, err
+	}
+	v.pool = pool
+
+	return v, nil
+}
+
+// parsePublicKey decodes the raw base64 URL encoded public key encoded,
+// returning ErrInvalidPublicKey if it is not a valid key of the given
+// Algorithm.
+func parsePublicKey(alg Algorithm, encoded string) (publicKey, error) {
+	// be lenient of different base64 formats
+	spk := strings.Replace(encoded, "+", "-", -1)
+	spk = strings.Replace(spk, "/", "_", -1)
+	spk = strings.TrimRight(spk, "=")
+
+	pkv, err := base64.NewFromString(spk)
+	if err != nil || len(*pkv) != alg.publicKeySize() {
+		return publicKey{}, ErrInvalidPublicKey
+	}
+
+	return publicKey{alg: alg, key: []byte(*pkv)}, nil
+}
+
+// timeSince is replaced during testing
+var timeSince = func(rt time.Time) time.Duration {
+	return time.Since(rt)
+}
+
+// Verify verifies that the given request is signed by Manifold. It returns an
+// error if the signature is invalid.
+// The request body is not read directly, instead, body is read, allowing
+// buffering or duplication of the body to be handled outside of this method.
+// The signature is checked against exactly the bytes read from body; for
+// compressed bodies, see DecompressBody.
+func (v *Verifier) Verify(req *http.Request, body io.Reader) error {
+	return v.VerifyContext(req.Context(), req, body)
+}
+
+// VerifyContext behaves like Verify, returning once ctx is done, or once the
+// timeout set by WithVerifyTimeout is reached. The returned *Error then wraps
+// the context's error, so it can be checked with errors.Is.
+//
+// A read from body that is in progress when ctx is done can not be
+// interrupted. VerifyContext returns without waiting for it, and body is not
+// read again once it returns.
+func (v *Verifier) VerifyContext(ctx context.Context, req *http.Request, body io.Reader) error {
+	_, _, err := v.verify(ctx, req, body)
+	return err
+}
+
+// contextReader reads from r until ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, contextError(err)
+	}
+
+	// A read that returns once ctx is done, such as one unblocked by the
+	// cancellation, is reported as stopped by ctx.
+	n, err := c.r.Read(p)
+	if cerr := c.ctx.Err(); cerr != nil {
+		return n, contextError(cerr)
+	}
+
+	return n, err
+}
+
+// contextError returns the Error for a verification stopped by its context
+// being done with err.
+func contextError(err error) *Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Code: http.StatusRequestTimeout, Message: "Request verification timed out", category: Timeout, cause: err}
+	}
+
+	return &Error{Code: http.StatusRequestTimeout, Message: "Request verification was cancelled", category: Timeout, cause: err}
+}
+
+// VerifyReturningCanonical behaves like Verify, additionally returning the
+// canonical form of the request that the signature was checked against. This
+// allows it to be cached, or passed on, without being rebuilt. If verification
+// fails before the request is canonicalized, the canonical form is nil.
+//
+// The canonical form is also returned when the signature is invalid, so that
+// it can be compared with the signer's canonical form to debug mismatches.
+func (v *Verifier) VerifyReturningCanonical(req *http.Request, body io.Reader) ([]byte, error) {
+	_, b, err := v.verify(req.Context(), req, body)
+	return b, err
+}
+
+// VerifyParsed verifies req using signature data already extracted from its
+// headers, for pipelines that have parsed it in an earlier stage. sig is the
+// request's signature, signedHeaders the names listed in its X-Signed-Headers
+// header, and date the time from its Date header.
+//
+// Only the time skew, canonical form, and signature are checked; other checks
+// made by Verify, such as on signed expiry times or use limits, are skipped.
+// The request's X-Signed-Headers header is canonicalized from signedHeaders,
+// and any other signed headers, including Date, from the request itself.
+func (v *Verifier) VerifyParsed(req *http.Request, body io.Reader, sig *Signature, signedHeaders []string, date time.Time) error {
+	if sig == nil || sig.Value == nil || sig.PublicKey == nil || sig.Endorsement == nil {
+		return &Error{Code: 400, Message: "Missing X-Signature header", category: Malformed, cause: ErrMissingSignature}
+	}
+
+	alg, ok := parseAlgorithm(string(sig.Algorithm))
+	if !ok {
+		return &Error{Code: 400, Message: "Unsupported X-Signature-Algorithm", category: Malformed}
+	}
+	if alg != v.alg {
+		return &Error{Code: 401, Message: "Request was not signed with a trusted algorithm", category: Unauthorized}
+	}
+	if !alg.validLengths(sig) || (!v.certEndorsement && len(*sig.Endorsement) != alg.signatureSize()) {
+		return componentLengthError()
+	}
+	parsed := *sig
+	parsed.Algorithm = alg
+
+	if err := CheckSkew(date, date.Add(v.since(date)), v.skewWindow(req)); err != nil {
+		return v.semanticError(err)
+	}
+
+	h := make(http.Header, len(req.Header)+1)
+	for k, vs := range req.Header {
+		h[k] = vs
+	}
+	h.Set("X-Signed-Headers", strings.Join(signedHeaders, " "))
+
+	r := *req
+	r.Header = h
+
+	b, err := v.canon.canonize(&r, body)
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	if err != nil {
+		return &Error{Code: 400, Message: "Unable to read request body", category: Malformed}
+	}
+
+	return v.validate(&parsed, b)
+}
+
+// VerifyReaderAt behaves like Verify, reading the request body from the first
+// size bytes of body. This avoids copying bodies that are already held in
+// memory-mapped or file-backed storage. body is read sequentially, and may be
+// read concurrently by other callers.
+func (v *Verifier) VerifyReaderAt(req *http.Request, body io.ReaderAt, size int64) error {
+	return v.Verify(req, io.NewSectionReader(body, 0, size))
+}
+
+// verify verifies req, returning its signature once it has been parsed, and its
+// canonical form once it has been built. body is read until ctx is done, or
+// the Verifier's verify timeout is reached.
+func (v *Verifier) verify(ctx context.Context, req *http.Request, body io.Reader) (*Signature, []byte, error) {
+	ctx, cancel := v.timeoutContext(ctx, time.Now())
+	defer cancel()
+
+	return v.abandonable(ctx, func(run *verifyRun) (*Signature, []byte, error) {
+		return v.verifyRequest(req, &contextReader{ctx: ctx, r: body}, false, run)
+	})
+}
+
+// timeoutContext returns a copy of ctx that is done once the Verifier's verify
+// timeout has passed since start, if it has one.
+func (v *Verifier) timeoutContext(ctx context.Context, start time.Time) (context.Context, context.CancelFunc) {
+	if v.verifyTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, start.Add(v.verifyTimeout))
+}
+
+// abandonable runs fn, returning its result, or an Error wrapping ctx's error
+// if ctx is done first. A blocked body read can not be interrupted, so fn is
+// then abandoned, and left to return in the background. Its run records no
+// uses of the signature, and it returns an error, as its run can no longer
+// be committed.
+func (v *Verifier) abandonable(ctx context.Context, fn func(*verifyRun) (*Signature, []byte, error)) (*Signature, []byte, error) {
+	if ctx.Done() == nil {
+		return fn(nil)
+	}
+
+	type result struct {
+		sig *Signature
+		b   []byte
+		err error
+	}
+
+	run := &verifyRun{}
+	done := make(chan result, 1)
+	go func() {
+		sig, b, err := fn(run)
+		done <- result{sig, b, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.sig, r.b, r.err
+	case <-ctx.Done():
+		if key, ok := run.abandon(); ok {
+			if key != "" {
+				v.rateLimit.refund(key, v.clock())
+			}
+			return nil, nil, contextError(ctx.Err())
+		}
+
+		// Verification completed as ctx was done.
+		r := <-done
+		return r.sig, r.b, r.err
+	}
+}
+
+// verifyRun tracks a verification that may be abandoned by verify on timeout,
+// so that its effects on the Verifier's state are kept only if its result is
+// returned.
+type verifyRun struct {
+	mu        sync.Mutex
+	abandoned bool
+	committed bool
+	charged   string
+}
+
+// charge records an attempt by key at now against l, returning false if key
+// has exceeded its limit. Attempts are not recorded once the run is abandoned.
+func (r *verifyRun) charge(l *rateLimiter, key string, now time.Time) bool {
+	if r == nil {
+		return l.allow(key, now)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.abandoned {
+		return true
+	}
+	if !l.allow(key, now) {
+		return false
+	}
+
+	r.charged = key
+	return true
+}
+
+// commit marks the run as complete, returning false if it has already been
+// abandoned. A committed run can not be abandoned.
+func (r *verifyRun) commit() bool {
+	if r == nil {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.abandoned {
+		return false
+	}
+
+	r.committed = true
+	return true
+}
+
+// abandon marks the run as abandoned, returning the key it charged to the rate
+// limit, if any. It returns false if the run has already been committed.
+func (r *verifyRun) abandon() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.committed {
+		return "", false
+	}
+
+	r.abandoned = true
+	return r.charged, true
+}
+
+// verifyRequest verifies req, as described by verify. Uses of the signature are
+// recorded only once run is committed.
+//
+// If stream is set, and the Verifier's options allow it, body is verified as
+// it is read, rather than held in memory, and no canonical form is returned.
+func (v *Verifier) verifyRequest(req *http.Request, body io.Reader, stream bool, run *verifyRun) (*Signature, []byte, error) {
+	if v.allowedNets != nil {
+		if err := v.checkOrigin(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if v.maxBodySize > 0 {
+		body = limitBody(body, v.maxBodySize)
+	}
+
+	if v.preVerify != nil {
+		if err := v.preVerify(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if v.authorizationHeader {
+		var err error
+		if req, err = unpackAuthorization(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if v.combinedSignature {
+		var err error
+		if req, err = unpackCombinedSignature(req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if v.bodyFooterMarker != nil {
+		var err error
+		if req, body, err = v.unpackFooter(req, body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if req.TLS != nil && req.TLS.Version < v.minTLSVersion {
+		return nil, nil, &Error{Code: 403, Message: "Request TLS version is too low", category: Forbidden}
+	}
+
+	if v.contentTypes != nil && !v.allowedContentType(req) {
+		return nil, nil, &Error{Code: 415, Message: "Request Content-Type is not allowed", category: ContentType}
+	}
+
+	sig, err := v.signatureFromHeader(req.Header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if v.rateLimit != nil {
+		// Only endorsed keys are charged, so that a key can not be locked out
+		// by requests from anyone who has seen it.
+		if err := v.checkEndorsement(sig); err != nil {
+			return sig, nil, err
+		}
+		if !run.charge(v.rateLimit, sig.PublicKey.String(), v.clock()) {
+			return sig, nil, &Error{Code: 429, Message: "Too many requests for this Public Key", category: RateLimited}
+		}
+	}
+
+	if v.strictHeaderCase {
+		if err := v.checkHeaderCase(req.Header); err != nil {
+			return sig, nil, err
+		}
+	}
+
+	if v.optionalHeaders != nil {
+		if err := v.checkMissingHeaders(req.Header); err != nil {
+			return sig, nil, err
+		}
+	}
+
+	for _, name := range v.criticalHeaders {
+		if _, ok := req.Header[name]; ok && !v.canon.isSigned(req.Header, name) {
+			return sig, nil, &Error{Code: 401, Message: "Critical header " + name + " was not signed", category: Unauthorized}
+		}
+	}
+
+	window, err := v.signedSkewWindow(req.Header, v.skewWindow(req))
+	if err != nil {
+		return sig, nil, err
+	}
+
+	if err := v.checkDate(req, window); err != nil {
+		return sig, nil, v.semanticError(err)
+	}
+
+	if err := v.checkValidity(req.Header); err != nil {
+		return sig, nil, v.semanticError(err)
+	}
+
+	var b []byte
+	var master publicKey
+	if stream && v.streamsBody() {
+		var head bytes.Buffer
+		v.canon.writeHead(&head, req)
+		if master, err = v.checkReader(sig, head.Bytes(), v.canon.streamedBody(body)); err != nil {
+			return sig, nil, err
+		}
+	} else {
+		b, err = v.canon.canonize(req, body)
+		if e, ok := err.(*Error); ok {
+			return sig, nil, e
+		}
+		if err != nil {
+			return sig, nil, &Error{Code: 400, Message: "Unable to read request body", category: Malformed}
+		}
+
+		if master, err = v.check(sig, b); err != nil {
+			return sig, b, err
+		}
+	}
+
+	if !run.commit() {
+		return sig, b, &Error{Code: http.StatusRequestTimeout, Message: "Request verification timed out", category: Timeout}
+	}
+
+	v.observe(master)
+
+	if v.uses != nil && !v.uses.use(v.replayKey(req, sig), window, v.clock()) {
+		return sig, b, &Error{Code: 401, Message: "Signature has exceeded its permitted number of uses", category: Unauthorized}
+	}
+
+	return sig, b, nil
+}
+
+// CanonicalHeaderOrder returns the names of the headers, lowercased, that the
+// Verifier includes in the canonical form of req, in the order they appear.
+// The last is always x-signed-headers.
+func (v *Verifier) CanonicalHeaderOrder(req *http.Request) []string {
+	var names []string
+	for _, h := range v.canon.signedHeaders(req.Header) {
+		names = append(names, strings.ToLower(h))
+	}
+
+	return append(names, "x-signed-headers")
+}
+
+// VerifyWithBodyHash behaves like Verify, additionally returning the SHA-256
+// digest of the verified body, suitable as a deduplication key. The digest is
+// computed during the same read used for verification.
+func (v *Verifier) VerifyWithBodyHash(req *http.Request, body io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if err := v.Verify(req, io.TeeReader(body, h)); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// signatureFromHeader parses the signature from the X-Signature header in h,
+// ensuring the X-Signed-Headers header is also present.
+func (v *Verifier) signatureFromHeader(h http.Header) (*Signature, error) {
+	sigHeader := v.signatureHeader(h)
+	if sigHeader == "" {
+		return nil, &Error{Code: 400, Message: "Missing X-Signature header", category: Malformed, cause: ErrMissingSignature}
+	}
+
+	sig, err := ParseSignature(sigHeader)
+	if errors.Is(err, errComponentLength) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &Error{Code: 400, Message: "Could not parse X-Signature header", category: Malformed}
+	}
+
+	alg, ok := parseAlgorithm(h.Get("X-Signature-Algorithm"))
+	if !ok {
+		return nil, &Error{Code: 400, Message: "Unsupported X-Signature-Algorithm", category: Malformed}
+	}
+	if alg != v.alg {
+		return nil, &Error{Code: 401, Message: "Request was not signed with a trusted algorithm", category: Unauthorized}
+	}
+	if !alg.validLengths(sig) || (!v.certEndorsement && len(*sig.Endorsement) != alg.signatureSize()) {
+		return nil, componentLengthError()
+	}
+	sig.Algorithm = alg
+
+	if _, ok := h["X-Signed-Headers"]; !ok {
+		return nil, &Error{Code: 400, Message: "Missing X-Signed-Headers header", category: Malformed}
+	}
+
+	if v.canon.structuredHeaders {
+		if _, ok := parseStructuredList(h.Get("X-Signed-Headers")); !ok {
+			return nil, &Error{Code: 400, Message: "Could not parse X-Signed-Headers header", category: Malformed}
+		}
+	}
+
+	if !listsHeaders(v.canon.signedHeaders(h)) && !v.allowEmptySignedHeaders {
+		return nil, &Error{Code: 400, Message: "X-Signed-Headers header lists no headers", category: Malformed}
+	}
+
+	return sig, nil
+}
+
+// signatureHeader returns the encoded signature from h, or an empty string if
+// there is none.
+//
+// If the Verifier accepts split signature headers, and the X-Signature header
+// is not present, the signature is assembled from the X-Signature-Value,
+// X-Signature-Key, and X-Signature-Endorsement headers, if all are present.
+func (v *Verifier) signatureHeader(h http.Header) string {
+	sig := h.Get("X-Signature")
+	if sig == "" && v.authorizationHeader {
+		if r, err := unpackAuthorization(&http.Request{Header: h}); err == nil {
+			sig = r.Header.Get("X-Signature")
+		}
+	}
+
+	if sig != "" || !v.splitSignature {
+		return sig
+	}
+
+	parts := []string{
+		h.Get("X-Signature-Value"),
+		h.Get("X-Signature-Key"),
+		h.Get("X-Signature-Endorsement"),
+	}
+	for _, p := range parts {
+		if p == "" {
+			return ""
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// checkDate behaves like checkHeaderDate, measuring skew against the time req
+// was received, if known from a function set by WithReceiptTime.
+func (v *Verifier) checkDate(req *http.Request, window time.Duration) error {
+	var received time.Time
+	if v.receiptTime != nil {
+		received = v.receiptTime(req)
+	}
+
+	return v.checkHeaderDate(req.Header, received, window)
+}
+
+// checkHeaderDate returns an error if the Date header in h can not be read, or
+// is further than window from received, or from the Verifier's clock if
+// received is zero.
+//
+// Dates with a UTC offset other than Z are permitted; skew is measured between
+// instants, so the offset does not affect the result.
+func (v *Verifier) checkHeaderDate(h http.Header, received time.Time, window time.Duration) error {
+	rt, err := time.Parse(time.RFC3339, h.Get("Date"))
+	if err != nil {
+		return &Error{Code: 400, Message: "Unable to read request date", category: Malformed}
+	}
+
+	now := received
+	if now.IsZero() {
+		now = rt.Add(v.since(rt))
+	}
+
+	return CheckSkew(rt, now, window)
+}
+
+// since returns the time elapsed since t, according to the Verifier's clock.
+func (v *Verifier) since(t time.Time) time.Duration {
+	if v.now == nil {
+		return timeSince(t)
+	}
+
+	return v.now().Sub(t)
+}
+
+// clock returns the current time, according to the Verifier's clock.
+func (v *Verifier) clock() time.Time {
+	if v.now == nil {
+		return time.Now()
+	}
+
+	return v.now()
+}
+
+// CheckSkew returns an error if requestDate is more than window from now, in
+// either direction. A request dated exactly window from now is permitted.
+//
+// This is the skew check performed by Verify, using PermittedTimeSkew as the
+// window.
+func CheckSkew(requestDate time.Time, now time.Time, window time.Duration) error {
+	delta := now.Sub(requestDate)
+	if delta < 0 {
+		delta = -delta
+	}
+
+	if delta > window {
+		return &Error{Code: 400, Message: "Request time skew is too great", category: TimeWindow, cause: ErrTimeSkew}
+	}
+
+	return nil
+}
+
+// allowedContentType reports whether the media type of req's Content-Type is
+// one of the Verifier's allowed content types. Requests with neither a body
+// nor a Content-Type are allowed.
+func (v *Verifier) allowedContentType(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" && !hasBody(req) {
+		return true
+	}
+
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, ct := range v.contentTypes {
+		if strings.EqualFold(mt, ct) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasBody reports whether req has a body, or may have one, as for chunked
+// requests of unknown length.
+func hasBody(req *http.Request) bool {
+	return req.ContentLength != 0 || (req.Body != nil && req.Body != http.NoBody)
+}
+
+// checkValidity returns an error if the signed X-Not-Before or X-Expires
+// headers in h show that the current time is outside of the signature's
+// validity period. Unsigned occurrences of these headers are ignored.
+func (v *Verifier) checkValidity(h http.Header) error {
+	if v.canon.isSigned(h, "X-Not-Before") {
+		nb, err := time.Parse(time.RFC3339, h.Get("X-Not-Before"))
+		if err != nil {
+			return &Error{Code: 400, Message: "Unable to read request not-before time", category: Malformed}
+		}
+
+		if v.since(nb) < 0 {
+			return &Error{Code: 400, Message: "Request was presented before its not-before time", category: TimeWindow}
+		}
+	}
+
+	if v.canon.isSigned(h, "X-Expires") {
+		exp, err := time.Parse(time.RFC3339, h.Get("X-Expires"))
+		if err != nil {
+			return &Error{Code: 400, Message: "Unable to read request expiry time", category: Malformed}
+		}
+
+		if v.since(exp) > 0 {
+			return &Error{Code: 400, Message: "Request signature has expired", category: TimeWindow}
+		}
+	}
+
+	return nil
+}
+
+// checkHeaderCase returns an error if a header listed in the X-Signed-Headers
+// header in h is present in h, but only with a name differing in case.
+func (v *Verifier) checkHeaderCase(h http.Header) error {
+	for _, name := range v.canon.signedHeaders(h) {
+		if strings.EqualFold(name, "host") {
+			continue
+		}
+		if _, ok := h[name]; ok {
+			continue
+		}
+
+		for k := range h {
+			if strings.EqualFold(k, name) {
+				return &Error{Code: 401, Message: "Signed header " + name + " does not match the case of the request header", category: Unauthorized}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkMissingHeaders returns an error if a header listed in the
+// X-Signed-Headers header in h is absent from h, and is not one of the
+// Verifier's optional signed headers. Host is taken from the request, so it is
+// never absent.
+func (v *Verifier) checkMissingHeaders(h http.Header) error {
+	for _, name := range v.canon.signedHeaders(h) {
+		ch := http.CanonicalHeaderKey(name)
+		if ch == "" || ch == "Host" || v.optionalHeaders[ch] {
+			continue
+		}
+
+		_, ok := h[ch]
+		if _, exact := h[name]; !ok && !exact {
+			return &Error{Code: 400, Message: "Signed header " + name + " is missing", category: Malformed}
+		}
+	}
+
+	return nil
+}
+
+// semanticError returns err with the Verifier's semantic error code, if one is
+// configured and err is a *Error caused by a well formed request that is not
+// valid, such as one outside the permitted time skew.
+func (v *Verifier) semanticError(err error) error {
+	if e, ok := err.(*Error); ok && e.category == TimeWindow && v.semanticCode != 0 {
+		e.Code = v.semanticCode
+	}
+
+	return err
+}
+
+// skewWindow returns the permitted time skew for req. This is the window
+// configured for the longest path prefix matching the request, or
+// PermittedTimeSkew if none match.
+func (v *Verifier) skewWindow(req *http.Request) time.Duration {
+	window := PermittedTimeSkew
+	matched := -1
+	for prefix, d := range v.skewByPath {
+		if len(prefix) > matched && strings.HasPrefix(req.URL.Path, prefix) {
+			window = d
+			matched = len(prefix)
+		}
+	}
+
+	return window
+}
+
+// signedSkewWindow returns the time skew window declared by the signed
+// X-Max-Skew header in h, in seconds, capped at the Verifier's ceiling. If the
+// Verifier does not honor the header, or it is not signed, window is returned.
+func (v *Verifier) signedSkewWindow(h http.Header, window time.Duration) (time.Duration, error) {
+	if v.maxSkewCeiling <= 0 || !v.canon.isSigned(h, "X-Max-Skew") {
+		return window, nil
+	}
+
+	secs, err := strconv.ParseInt(strings.TrimSpace(h.Get("X-Max-Skew")), 10, 64)
+	if err != nil || secs < 0 {
+		return 0, &Error{Code: 400, Message: "Unable to read request max skew", category: Malformed}
+	}
+
+	// Compared in seconds, so large values can not overflow a Duration.
+	if secs >= int64(v.maxSkewCeiling/time.Second) {
+		return v.maxSkewCeiling, nil
+	}
+
+	return time.Duration(secs) * time.Second, nil
+}
+
+// isSigned reports whether the named header is included in the X-Signed-Headers
+// list in h. The list may be space delimited, or a structured field inner list
+// as read with WithStructuredSignedHeaders.
+func isSigned(h http.Header, name string) bool {
+	_, structured := parseStructuredList(h.Get("X-Signed-Headers"))
+	return (&canonOptions{structuredHeaders: structured}).isSigned(h, name)
+}
+
+// isSigned reports whether the named header is included in the X-Signed-Headers
+// list in h, as read with o.
+func (o *canonOptions) isSigned(h http.Header, name string) bool {
+	for _, sh := range o.signedHeaders(h) {
+		if strings.EqualFold(sh, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate validates sig against b, using the first of the Verifier's trusted
+// master keys that endorses the signature's public key.
+func (v *Verifier) validate(sig *Signature, b []byte) error {
+	master, err := v.check(sig, b)
+	if err != nil {
+		return err
+	}
+
+	v.observe(master)
+	return nil
+}
+
+// check validates sig against b, as validate does, returning the master key
+// that endorses the signature's public key. The master key is nil for
+// certificate endorsements.
+func (v *Verifier) check(sig *Signature, b []byte) (publicKey, error) {
+	if v.certEndorsement {
+		return publicKey{}, v.validateCert(sig, b)
+	}
+
+	master, ok := v.endorser(sig)
+	if !ok {
+		return publicKey{}, v.unendorsedError()
+	}
+
+	if !sig.Algorithm.verify([]byte(*sig.PublicKey), b, []byte(*sig.Value)) {
+		return publicKey{}, &Error{Code: 401, Message: "Request was not signed by included Public Key", category: Unauthorized, cause: ErrBadSignature}
+	}
+
+	return master, nil
+}
+
+// streamsBody reports whether the Verifier can verify a request body as it is
+// read, with checkReader. Bodies are otherwise read into memory to build the
+// canonical form.
+func (v *Verifier) streamsBody() bool {
+	return v.alg == Ed25519 && !v.certEndorsement && v.canon.streamsBody()
+}
+
+// checkReader behaves like check, for the canonical form made up of head
+// followed by the contents of body, which is read without being held in
+// memory.
+func (v *Verifier) checkReader(sig *Signature, head []byte, body io.Reader) (publicKey, error) {
+	master, ok := v.endorser(sig)
+	if !ok {
+		return publicKey{}, v.unendorsedError()
+	}
+
+	ok, err := verifyEd25519Reader([]byte(*sig.PublicKey), head, body, []byte(*sig.Value))
+	if e, isErr := err.(*Error); isErr {
+		return publicKey{}, e
+	}
+	if err != nil {
+		return publicKey{}, &Error{Code: 400, Message: "Unable to read request body", category: Malformed}
+	}
+	if !ok {
+		return publicKey{}, &Error{Code: 401, Message: "Request was not signed by included Public Key", category: Unauthorized, cause: ErrBadSignature}
+	}
+
+	return master, nil
+}
+
+// observe records a verified request endorsed by master with the Verifier's
+// rotation, if any.
+func (v *Verifier) observe(master publicKey) {
+	if v.rotation != nil && !master.isZero() {
+		v.rotation.observe(master, v.clock())
+	}
+}
+
+// checkEndorsement returns an error if sig's public key is not endorsed by one
+// of the Verifier's trusted master keys. The signature value itself is not
+// checked.
+func (v *Verifier) checkEndorsement(sig *Signature) error {
+	if v.certEndorsement {
+		return v.checkCert(sig)
+	}
+
+	if _, ok := v.endorser(sig); !ok {
+		return v.unendorsedError()
+	}
+
+	return nil
+}
+
+// unendorsedError returns the Error for a public key that is not endorsed by
+// any of the Verifier's trusted master keys.
+func (v *Verifier) unendorsedError() *Error {
+	if len(v.pool) > 0 {
+		return &Error{Code: 401, Message: "Request Public Key was not endorsed by any trusted master key", category: Unauthorized, cause: ErrUnendorsedKey}
+	}
+	return &Error{Code: 401, Message: "Request Public Key was not endorsed by Manifold", category: Unauthorized, cause: ErrUnendorsedKey}
+}
+
+// endorser returns the Verifier's trusted master key that endorses sig's
+// public key, and false if none do. Master keys are tried in order. Successful
+// checks are cached, as the same live key is typically used for many requests.
+func (v *Verifier) endorser(sig *Signature) (publicKey, bool) {
+	masters := v.masters()
+	now := v.clock()
+	if v.endorsements != nil {
+		if pk, ok := v.endorsements.get(sig, masters, now); ok {
+			return pk, true
+		}
+	}
+
+	for _, pk := range masters {
+		if pk.verify(sig.Algorithm, []byte(*sig.PublicKey), []byte(*sig.Endorsement)) {
+			if v.endorsements != nil {
+				v.endorsements.add(sig, pk, now)
+			}
+			return pk, true
+		}
+	}
+
+	return publicKey{}, false
+}
+
+// masters returns all of the master keys trusted by the Verifier. Keys from
+// its KeySource are Ed25519 keys.
+func (v *Verifier) masters() []publicKey {
+	masters := append([]publicKey{v.pk}, v.pool...)
+	if v.keys == nil {
+		return masters
+	}
+
+	// Sources that select keys by time, such as ScheduleKeys, use the
+	// Verifier's clock.
+	if ks, ok := v.keys.(interface {
+		keysAt(time.Time) []ed25519.PublicKey
+	}); ok {
+		return append(masters, ed25519Keys(ks.keysAt(v.clock()))...)
+	}
+
+	return append(masters, ed25519Keys(v.keys.Keys())...)
+}
+
+// respond writes e to rw, using the Verifier's configured error responder, or
+// its configured error message field.
+func (v *Verifier) respond(rw http.ResponseWriter, e *Error) {
+	if v.errorResponder != nil {
+		v.errorResponder(rw, e)
+		return
+	}
+
+	if v.errorField != "" {
+		e.field = v.errorField
+	}
+
+	e.Respond(rw)
+}
+
+// Wrap wraps the provided Handler, returning a new Handler that will verify
+// the request before passing it through to the Handler. If the request is
+// invalid,  Wrap will respond appropriately through the RequestWriter
+//
+// Wrap can be used directly as middleware for routers such as go-chi:
+//
+//	r := chi.NewRouter()
+//	r.Use(v.Wrap)
+func (v *Verifier) Wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		v.Negroni().ServeHTTP(rw, req, handler.ServeHTTP)
+	})
+}
+
+// WrapFunc is the HandlerFunc version of Wrap.
+func (v *Verifier) WrapFunc(handler http.HandlerFunc) http.Handler {
+	return v.Wrap(http.HandlerFunc(handler))
+}
+
+// Middleware implements the Negroni middleware interface for funcs
+type Middleware func(http.ResponseWriter, *http.Request, http.HandlerFunc)
+
+func (m Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	m(rw, r, next)
+}
+
+// Negroni returns a Negroni compatible middleware for verifying requests.
+// This middleware behaves like Wrap; it will not pass through to the next
+// Handler in the chain if the request does not have a valid signature. The
+// verified Signature is available from the request's context through
+// SignatureFromContext.
+func (v *Verifier) Negroni() Middleware {
+	return Middleware(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+		start := time.Now()
+
+		if v.unsignedHandler != nil && v.bodyFooterMarker == nil && v.signatureHeader(req.Header) == "" {
+			v.audit(start, req, AuditUnsigned, nil, nil)
+			v.unsignedHandler.ServeHTTP(rw, req)
+			return
+		}
+
+		// The verify timeout covers reading the body, as well as verifying
+		// it.
+		ctx, cancel := v.timeoutContext(req.Context(), start)
+		defer cancel()
+
+		// body and spilled are only used once verification succeeds, as an
+		// abandoned verification may still be setting them.
+		var body []byte
+		var spilled *spillFile
+		sig, _, err := v.abandonable(ctx, func(run *verifyRun) (*Signature, []byte, error) {
+			var err error
+			body, spilled, err = v.readBody(&contextReader{ctx: ctx, r: req.Body})
+			if err != nil {
+				e, ok := err.(*Error)
+				if !ok {
+					e = &Error{Code: 400, Message: "Could not ready body from request", category: Malformed}
+				}
+				return nil, nil, e
+			}
+
+			var b io.Reader = bytes.NewReader(body)
+			if spilled != nil {
+				// Verification reads the file by offset, leaving it
+				// positioned at its start for the handler.
+				b = io.NewSectionReader(spilled, 0, spilled.size)
+			}
+
+			sig, cb, err := v.verifyRequest(req, &contextReader{ctx: ctx, r: b}, spilled != nil, run)
+			if err != nil && spilled != nil {
+				spilled.Close() // nolint: errcheck
+			}
+			return sig, cb, err
+		})
+		if err != nil {
+			e, ok := err.(*Error)
+			if !ok {
+				e = &Error{Code: 401, Message: "Could not validate authenticity of the request", category: Unauthorized}
+			}
+
+			v.audit(start, req, AuditRejected, sig, e)
+			v.respond(rw, e)
+			return
+		}
+
+		v.audit(start, req, AuditVerified, sig, nil)
+
+		defer req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if spilled != nil {
+			defer spilled.Close()
+			req.Body = spilled
+		}
+
+		req = req.WithContext(context.WithValue(req.Context(), signatureKey{}, sig))
+
+		if v.deadlineFromExpiry && v.canon.isSigned(req.Header, "X-Expires") {
+			// The expiry was validated by Verify, so it parses.
+			exp, _ := time.Parse(time.RFC3339, req.Header.Get("X-Expires"))
+			ctx, cancel := context.WithDeadline(req.Context(), exp)
+			defer cancel()
+
+			req = req.WithContext(ctx)
+		}
+
+		if v.bodyTap != nil && spilled == nil {
+			select {
+			case v.bodyTap <- append([]byte(nil), body...):
+			default:
+			}
 		}
 
 		next(rw, req)
@@ -2,11 +2,25 @@ package signature
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
 )
 
 func init() {
@@ -57,6 +71,33 @@ func ExampleVerifier_Verify() {
 	// Output: Signature is ok!
 }
 
+func ExampleVerifier_VerifyReturningCanonical() {
+	body := "{}"
+	req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", bytes.NewBufferString(body))
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signed-Headers", "host date content-type")
+	req.Header.Set("X-Signature", testSignature)
+
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+	verifier, _ := NewVerifier(dummyKey)
+
+	// The canonical form is returned even if the signature is invalid, so it
+	// can be compared with the signer's.
+	b, err := verifier.VerifyReturningCanonical(req, bytes.NewBufferString(body))
+	fmt.Println(err)
+	fmt.Println(string(b))
+
+	// Output:
+	// Request was not signed by included Public Key
+	// put /v1/resources
+	// host: 127.0.0.1:4567
+	// date: 2017-03-05T23:53:08Z
+	// content-type: application/json
+	// x-signed-headers: host date content-type
+	// {}
+}
+
 func newReq() *http.Request {
 	body := bytes.NewBufferString("{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\",\"plan\":\"low\",\"product\":\"generators\",\"region\":\"aws::us-east-1\",\"user_id\":\"200e7aeg2kf2d6nud8jran3zxnz5j\"}\n")
 	req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources/2686c96868emyj61cgt2ma7vdntg4", body)
@@ -71,6 +112,73 @@ func newReq() *http.Request {
 	return req
 }
 
+// testKeys is a master and live key pair used to produce signatures in tests.
+type testKeys struct {
+	master      ed25519.PrivateKey
+	live        ed25519.PrivateKey
+	endorsement []byte
+}
+
+func newTestKeys() *testKeys {
+	master := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	live := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+
+	return &testKeys{
+		master:      master,
+		live:        live,
+		endorsement: ed25519.Sign(master, live.Public().(ed25519.PublicKey)),
+	}
+}
+
+// masterKey returns the base64 encoded master public key.
+func (k *testKeys) masterKey() string {
+	return base64.New(k.master.Public().(ed25519.PublicKey)).String()
+}
+
+func (k *testKeys) verifier(t *testing.T, opts ...Option) *Verifier {
+	v, err := NewVerifier(k.masterKey(), opts...)
+	if err != nil {
+		t.Fatal("could not create verifier:", err)
+	}
+
+	return v
+}
+
+// sign sets the X-Signature header on req, signing the given canonical form.
+func (k *testKeys) sign(req *http.Request, canonical []byte) {
+	sig := &Signature{
+		Value:       base64.New(ed25519.Sign(k.live, canonical)),
+		PublicKey:   base64.New(k.live.Public().(ed25519.PublicKey)),
+		Endorsement: base64.New(k.endorsement),
+	}
+	req.Header.Set("X-Signature", sig.String())
+}
+
+// newSignedReq returns a request with the given body, signed over its default
+// canonical form.
+// signedAt sets a Verifier's clock to the Date of requests built by
+// newSignedReq. Verifiers whose work may be abandoned use it, so that work left
+// running does not read timeSince while other tests replace it.
+var signedAt = WithClock(func() time.Time { return time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC) })
+
+func (k *testKeys) newSignedReq(t *testing.T, method, url, body string) *http.Request {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal("could not create request:", err)
+	}
+
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("X-Signed-Headers", "host date")
+
+	b, err := Canonize(req, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal("could not canonize request:", err)
+	}
+	k.sign(req, b)
+
+	return req
+}
+
 func TestWrap(t *testing.T) {
 	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
 	verifier, _ := NewVerifier(dummyKey)
@@ -148,3 +256,1362 @@ func TestWrap(t *testing.T) {
 	})
 
 }
+
+func TestVerifyWithBodyHash(t *testing.T) {
+	keys := newTestKeys()
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+	v := keys.verifier(t)
+
+	t.Run("valid", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		sum, err := v.VerifyWithBodyHash(req, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal("expected signature to verify, got:", err)
+		}
+
+		expected := sha256.Sum256([]byte(body))
+		if !bytes.Equal(sum, expected[:]) {
+			t.Errorf("body hash did not match: %x", sum)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		sum, err := v.VerifyWithBodyHash(req, bytes.NewBufferString("other"))
+		if err == nil {
+			t.Error("expected verification to fail")
+		}
+
+		if sum != nil {
+			t.Error("expected no body hash for an invalid request")
+		}
+	})
+}
+
+func TestVerifyNotBefore(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	timeSince = func(rt time.Time) time.Duration {
+		return now.Sub(rt)
+	}
+
+	newNotBeforeReq := func(nb string, signed bool) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Not-Before", nb)
+		req.Header.Set("X-Signed-Headers", "host date")
+		if signed {
+			req.Header.Set("X-Signed-Headers", "host date x-not-before")
+		}
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	tcs := []struct {
+		name    string
+		nb      string
+		signed  bool
+		message string
+	}{
+		{"before", "2017-03-05T23:54:08Z", true, "Request was presented before its not-before time"},
+		{"at", "2017-03-05T23:53:08Z", true, ""},
+		{"after", "2017-03-05T23:52:08Z", true, ""},
+		{"unsigned", "2017-03-05T23:54:08Z", false, ""},
+		{"invalid", "tomorrow", true, "Unable to read request not-before time"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Verify(newNotBeforeReq(tc.nb, tc.signed), &bytes.Buffer{})
+			if tc.message == "" {
+				if err != nil {
+					t.Error("expected signature to verify, got:", err)
+				}
+				return
+			}
+
+			if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != tc.message {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+	keys := newTestKeys()
+
+	resign := func(r *http.Request) {
+		b, _ := Canonize(r, strings.NewReader("body"))
+		keys.sign(r, b)
+	}
+	later := func() time.Time {
+		return time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC).Add(time.Hour)
+	}
+
+	tcs := []struct {
+		name     string
+		opts     []Option
+		mutate   func(*http.Request)
+		attempts int
+		category Category
+	}{
+		{"missing signature", nil, func(r *http.Request) { r.Header.Del("X-Signature") }, 1, Malformed},
+		{"bad signature header", nil, func(r *http.Request) { r.Header.Set("X-Signature", "nope") }, 1, Malformed},
+		{"missing signed headers", nil, func(r *http.Request) { r.Header.Del("X-Signed-Headers") }, 1, Malformed},
+		{"bad date", nil, func(r *http.Request) { r.Header.Set("Date", "yesterday") }, 1, Malformed},
+		{"altered request", nil, func(r *http.Request) { r.Header.Set("Date", "2017-03-05T23:53:09Z") }, 1, Unauthorized},
+		{"unendorsed key", nil, func(r *http.Request) { r.Header.Set("X-Signature", newReq().Header.Get("X-Signature")) }, 1, Unauthorized},
+		{"time skew", []Option{WithClock(later)}, func(*http.Request) {}, 1, TimeWindow},
+		{"time skew with semantic code", []Option{WithClock(later), WithSemanticErrorCode(422)}, func(*http.Request) {}, 1, TimeWindow},
+		{"expired", nil, func(r *http.Request) {
+			r.Header.Set("X-Expires", "2017-03-05T23:53:00Z")
+			r.Header.Set("X-Signed-Headers", "host date x-expires")
+			resign(r)
+		}, 1, TimeWindow},
+		{"too large", []Option{WithMaxBodySize(1)}, func(*http.Request) {}, 1, TooLarge},
+		{"content type", []Option{WithAllowedContentTypes("application/json")}, func(r *http.Request) {
+			r.Header.Set("Content-Type", "text/plain")
+		}, 1, ContentType},
+		{"network", []Option{WithAllowedCIDRs("10.0.0.0/8")}, func(r *http.Request) { r.RemoteAddr = "192.168.0.1:1234" }, 1, Forbidden},
+		{"tls version", []Option{WithMinTLSVersion(tls.VersionTLS12)}, func(r *http.Request) { r.TLS = &tls.ConnectionState{Version: tls.VersionTLS11} }, 1, Forbidden},
+		{"rate limited", []Option{WithPerKeyRateLimit(1, time.Hour)}, func(*http.Request) {}, 2, RateLimited},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			v := keys.verifier(t, tc.opts...)
+
+			var err error
+			for i := 0; i < tc.attempts; i++ {
+				req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+				tc.mutate(req)
+				err = v.Verify(req, strings.NewReader("body"))
+			}
+
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatal("expected an Error, got:", err)
+			}
+
+			if e.Category() != tc.category {
+				t.Errorf("expected category %s, got %s", tc.category, e.Category())
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		wait := make(chan struct{})
+		defer close(wait)
+
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		err := keys.verifier(t, WithVerifyTimeout(10*time.Millisecond), signedAt).Verify(req, &slowReader{r: strings.NewReader("body"), wait: wait})
+		if e, ok := err.(*Error); !ok || e.Category() != Timeout {
+			t.Errorf("expected a %s Error, got: %v", Timeout, err)
+		}
+	})
+
+	t.Run("created outside the package", func(t *testing.T) {
+		e := &Error{Code: 413, Message: "Request body is too large"}
+		if e.Category() != TooLarge {
+			t.Errorf("expected category %s, got %s", TooLarge, e.Category())
+		}
+	})
+}
+
+func TestErrorSentinels(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+
+	tcs := []struct {
+		name     string
+		mutate   func(*http.Request)
+		since    time.Duration
+		code     int
+		sentinel error
+	}{
+		{"missing signature", func(r *http.Request) { r.Header.Del("X-Signature") }, time.Second, 400, ErrMissingSignature},
+		{"time skew", func(*http.Request) {}, time.Hour, 400, ErrTimeSkew},
+		{"unendorsed key", func(r *http.Request) { r.Header.Set("X-Signature", newReq().Header.Get("X-Signature")) }, time.Second, 401, ErrUnendorsedKey},
+		{"altered request", func(r *http.Request) { r.Header.Set("Date", "2017-03-05T23:53:09Z") }, time.Second, 401, ErrBadSignature},
+		{"unparseable signature", func(r *http.Request) { r.Header.Set("X-Signature", "nope") }, time.Second, 400, nil},
+	}
+
+	sentinels := []error{ErrMissingSignature, ErrTimeSkew, ErrUnendorsedKey, ErrBadSignature}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			timeSince = func(time.Time) time.Duration { return tc.since }
+
+			req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+			tc.mutate(req)
+
+			err := v.Verify(req, &bytes.Buffer{})
+			if e, ok := err.(*Error); !ok || e.Code != tc.code {
+				t.Fatalf("expected a %d Error, got: %v", tc.code, err)
+			}
+
+			for _, s := range sentinels {
+				if errors.Is(err, s) != (s == tc.sentinel) {
+					t.Errorf("unexpected result from errors.Is(err, %q)", s)
+				}
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		(&Error{Code: 401, Message: "Request was not signed by included Public Key", cause: ErrBadSignature}).Respond(rw)
+
+		if rw.Body.String() != `{"message":"Request was not signed by included Public Key"}` {
+			t.Error("unexpected body:", rw.Body.String())
+		}
+	})
+}
+
+const testSignature = "Nb9iJZVDFrcf8-dw7AsuSCPtdoxoAr61YVWQe-5b9z_YiuQW73wR7RRsDBPnrBMtXIg_h8yKWsr-ZNRgYbM7CA FzNbTkRjAGjkpwHUbAhjvLsIlAlL_M6EUh5E9OVEwXs qGR6iozBfLUCHbRywz1mHDdGYeqZ0JEcseV4KcwjEVeZtQN54odcJ1_QyZkmHacbQeHEai2-Aw9EF8-Ceh09Cg"
+
+func TestParseSignature(t *testing.T) {
+	sig, err := ParseSignature(testSignature)
+	if err != nil {
+		t.Fatal("could not parse signature:", err)
+	}
+
+	if sig.String() != testSignature {
+		t.Errorf("parsed signature did not round trip: %s", sig)
+	}
+
+	if len(*sig.Value) != ed25519.SignatureSize || len(*sig.PublicKey) != ed25519.PublicKeySize {
+		t.Error("unexpected component lengths")
+	}
+
+	t.Run("components do not alias", func(t *testing.T) {
+		sig, _ := ParseSignature(testSignature)
+		key := append([]byte(nil), *sig.PublicKey...)
+
+		*sig.Value = append(*sig.Value, 0xFF, 0xFF, 0xFF)
+		if !bytes.Equal(*sig.PublicKey, key) {
+			t.Error("appending to the value modified the public key")
+		}
+	})
+
+	t.Run("allocations", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(100, func() {
+			ParseSignature(testSignature) // nolint: errcheck
+		})
+
+		if allocs > 2 {
+			t.Errorf("expected at most 2 allocations, got %v", allocs)
+		}
+	})
+
+	t.Run("newline delimited", func(t *testing.T) {
+		parts := strings.Split(testSignature, " ")
+		for _, value := range []string{
+			strings.Join(parts, "\n"),
+			strings.Join(parts, "\r\n"),
+			strings.Join(parts, "\r\n ") + "\r\n",
+			parts[0] + "\n" + parts[1] + " " + parts[2],
+		} {
+			sig, err := ParseSignature(value)
+			if err != nil {
+				t.Errorf("could not parse %q: %s", value, err)
+				continue
+			}
+
+			if sig.String() != testSignature {
+				t.Errorf("unexpected signature parsed from %q: %s", value, sig)
+			}
+		}
+	})
+
+	for _, tc := range []string{"", "a b", "a b c d", "a  b", "a b !", "a\nb", "a\nb\nc\nd", "a\n\nb\nc"} {
+		t.Run(fmt.Sprintf("invalid %q", tc), func(t *testing.T) {
+			if _, err := ParseSignature(tc); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func BenchmarkParseSignature(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseSignature(testSignature); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCheckSkew(t *testing.T) {
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+
+	tcs := []struct {
+		name    string
+		date    time.Time
+		success bool
+	}{
+		{"now", now, true},
+		{"past boundary", now.Add(-PermittedTimeSkew), true},
+		{"past outside", now.Add(-PermittedTimeSkew - time.Nanosecond), false},
+		{"future boundary", now.Add(PermittedTimeSkew), true},
+		{"future outside", now.Add(PermittedTimeSkew + time.Nanosecond), false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckSkew(tc.date, now, PermittedTimeSkew)
+			if tc.success && err != nil {
+				t.Error("expected skew to be permitted, got:", err)
+			}
+
+			if !tc.success {
+				if e, ok := err.(*Error); !ok || e.Code != 400 {
+					t.Error("expected a 400 Error, got:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorRespond(t *testing.T) {
+	t.Run("message", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		(&Error{Code: 401, Message: "nope"}).Respond(rw)
+
+		if rw.Code != 401 {
+			t.Error("Wrong status code returned:", rw.Code)
+		}
+
+		if rw.Body.String() != `{"message":"nope"}` {
+			t.Error("Unexpected body:", rw.Body.String())
+		}
+	})
+
+	t.Run("extra", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		e := &Error{Code: 400, Message: "nope", Extra: map[string]interface{}{
+			"request_id": "abc123",
+			"retry":      false,
+			"message":    "ignored",
+		}}
+		e.Respond(rw)
+
+		if rw.Body.String() != `{"message":"nope","request_id":"abc123","retry":false}` {
+			t.Error("Unexpected body:", rw.Body.String())
+		}
+
+		if rw.Header().Get("Content-Length") != fmt.Sprint(rw.Body.Len()) {
+			t.Error("Wrong content length:", rw.Header().Get("Content-Length"))
+		}
+	})
+}
+
+func TestVerifyReturningCanonical(t *testing.T) {
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+	verifier, _ := NewVerifier(dummyKey)
+
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\",\"plan\":\"low\",\"product\":\"generators\",\"region\":\"aws::us-east-1\",\"user_id\":\"200e7aeg2kf2d6nud8jran3zxnz5j\"}\n"
+	expected, err := Canonize(newReq(), bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal("could not canonize request:", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		b, err := verifier.VerifyReturningCanonical(newReq(), bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal("expected signature to verify, got:", err)
+		}
+
+		if !bytes.Equal(b, expected) {
+			t.Errorf("canonical form did not match:\n%s", b)
+		}
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		req := newReq()
+		req.Header.Set("Date", "2017-03-05T23:53:09Z")
+
+		b, err := verifier.VerifyReturningCanonical(req, bytes.NewBufferString(body))
+		if err == nil {
+			t.Error("expected verification to fail")
+		}
+
+		if !bytes.Contains(b, []byte("date: 2017-03-05T23:53:09Z\n")) {
+			t.Errorf("unexpected canonical form:\n%s", b)
+		}
+	})
+
+	t.Run("not canonicalized", func(t *testing.T) {
+		req := newReq()
+		req.Header.Del("X-Signature")
+
+		b, err := verifier.VerifyReturningCanonical(req, bytes.NewBufferString(body))
+		if err == nil || b != nil {
+			t.Error("expected no canonical form and an error, got:", err)
+		}
+	})
+}
+
+func TestCanonicalHeaderOrder(t *testing.T) {
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+
+	t.Run("request order", func(t *testing.T) {
+		verifier, _ := NewVerifier(dummyKey)
+		order := verifier.CanonicalHeaderOrder(newReq())
+		expected := []string{"host", "date", "content-type", "content-length", "x-signed-headers"}
+		if !reflect.DeepEqual(order, expected) {
+			t.Errorf("unexpected header order: %v", order)
+		}
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		verifier, _ := NewVerifier(dummyKey, WithSortedSignedHeaders())
+		order := verifier.CanonicalHeaderOrder(newReq())
+		expected := []string{"content-length", "content-type", "date", "host", "x-signed-headers"}
+		if !reflect.DeepEqual(order, expected) {
+			t.Errorf("unexpected header order: %v", order)
+		}
+	})
+
+	t.Run("matches canonical form", func(t *testing.T) {
+		verifier, _ := NewVerifier(dummyKey)
+		req := newReq()
+		b, _ := Canonize(req, &bytes.Buffer{})
+		lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")[1:]
+
+		order := verifier.CanonicalHeaderOrder(req)
+		if len(lines) != len(order) {
+			t.Fatalf("expected %d headers, got %d", len(lines), len(order))
+		}
+
+		for i, name := range order {
+			if !strings.HasPrefix(lines[i], name+": ") {
+				t.Errorf("header %d is %q, expected %s", i, lines[i], name)
+			}
+		}
+	})
+}
+
+func TestVerifyExpires(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	timeSince = func(rt time.Time) time.Duration {
+		return now.Sub(rt)
+	}
+
+	newExpiresReq := func(exp string, signed bool) *http.Request {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Expires", exp)
+		req.Header.Set("X-Signed-Headers", "host date")
+		if signed {
+			req.Header.Set("X-Signed-Headers", "host date x-expires")
+		}
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+		return req
+	}
+
+	tcs := []struct {
+		name    string
+		exp     string
+		signed  bool
+		message string
+	}{
+		{"not yet expired", "2017-03-05T23:54:08Z", true, ""},
+		{"at expiry", "2017-03-05T23:53:08Z", true, ""},
+		{"expired", "2017-03-05T23:53:07Z", true, "Request signature has expired"},
+		{"unsigned", "2017-03-05T23:52:08Z", false, ""},
+		{"invalid", "yesterday", true, "Unable to read request expiry time"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Verify(newExpiresReq(tc.exp, tc.signed), &bytes.Buffer{})
+			if tc.message == "" {
+				if err != nil {
+					t.Error("expected signature to verify, got:", err)
+				}
+				return
+			}
+
+			if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != tc.message {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+}
+
+func TestVerifyReaderAt(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+	req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+
+	tcs := []struct {
+		name string
+		body string
+		size int64
+	}{
+		{"same body", body, int64(len(body))},
+		{"altered body", strings.ToUpper(body), int64(len(body))},
+		{"truncated", body, int64(len(body)) - 1},
+		{"trailing data", body + "\n", int64(len(body))},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := v.Verify(req, strings.NewReader(tc.body[:tc.size]))
+			err := v.VerifyReaderAt(req, strings.NewReader(tc.body), tc.size)
+
+			if (err == nil) != (expected == nil) {
+				t.Errorf("expected %v, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestCanonizeHeaderOrderIndependence(t *testing.T) {
+	headers := [][2]string{
+		{"Date", "2017-03-05T23:53:08Z"},
+		{"Content-Type", "application/json"},
+		{"Content-Length", "2"},
+		{"X-Alpha", "a"},
+		{"X-Bravo", "b"},
+		{"X-Charlie", "c"},
+		{"X-Delta", "d"},
+		{"X-Signed-Headers", "x-delta date x-bravo content-type host x-alpha content-length x-charlie"},
+	}
+
+	newHeaderReq := func(perm []int) *http.Request {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header = http.Header{}
+		for _, i := range perm {
+			req.Header.Set(headers[i][0], headers[i][1])
+		}
+		return req
+	}
+
+	expected := "put /v1/resources\n" +
+		"x-delta: d\n" +
+		"date: 2017-03-05T23:53:08Z\n" +
+		"x-bravo: b\n" +
+		"content-type: application/json\n" +
+		"host: 127.0.0.1:4567\n" +
+		"x-alpha: a\n" +
+		"content-length: 2\n" +
+		"x-charlie: c\n" +
+		"x-signed-headers: x-delta date x-bravo content-type host x-alpha content-length x-charlie\n" +
+		"{}"
+
+	// Repeat each permutation, as map iteration order is randomized per
+	// iteration rather than per insertion order.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		perm := r.Perm(len(headers))
+		b, err := Canonize(newHeaderReq(perm), bytes.NewBufferString("{}"))
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if string(b) != expected {
+			t.Fatalf("unexpected canonical form for insertion order %v: %q", perm, b)
+		}
+	}
+}
+
+func TestSignatureSignedBy(t *testing.T) {
+	keys := newTestKeys()
+	req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+	b, _ := Canonize(req, bytes.NewBufferString("body"))
+
+	sig, err := ParseSignature(req.Header.Get("X-Signature"))
+	if err != nil {
+		t.Fatal("could not parse signature:", err)
+	}
+
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+
+	tcs := []struct {
+		name     string
+		key      ed25519.PublicKey
+		b        []byte
+		expected bool
+	}{
+		{"live key", keys.live.Public().(ed25519.PublicKey), b, true},
+		{"other key", other.Public().(ed25519.PublicKey), b, false},
+		{"master key", keys.master.Public().(ed25519.PublicKey), b, false},
+		{"altered bytes", keys.live.Public().(ed25519.PublicKey), append(b, '!'), false},
+		{"short key", ed25519.PublicKey{1, 2, 3}, b, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sig.SignedBy(tc.key, tc.b); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestVerifyErrors(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	now := time.Date(2017, 3, 5, 23, 53, 9, 0, time.UTC)
+	timeSince = func(rt time.Time) time.Duration {
+		return now.Sub(rt)
+	}
+
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+	endorsedByOther := (&Signature{
+		Value:       base64.New(bytes.Repeat([]byte{1}, ed25519.SignatureSize)),
+		PublicKey:   base64.New(keys.live.Public().(ed25519.PublicKey)),
+		Endorsement: base64.New(ed25519.Sign(other, keys.live.Public().(ed25519.PublicKey))),
+	}).String()
+
+	tcs := []struct {
+		name    string
+		modify  func(*http.Request)
+		body    io.Reader
+		code    int
+		message string
+	}{
+		{
+			name:    "missing signature",
+			modify:  func(r *http.Request) { r.Header.Del("X-Signature") },
+			code:    400,
+			message: "Missing X-Signature header",
+		},
+		{
+			name:    "unparseable signature",
+			modify:  func(r *http.Request) { r.Header.Set("X-Signature", "not-a-signature") },
+			code:    400,
+			message: "Could not parse X-Signature header",
+		},
+		{
+			name:    "invalid signature encoding",
+			modify:  func(r *http.Request) { r.Header.Set("X-Signature", "!!! !!! !!!") },
+			code:    400,
+			message: "Could not parse X-Signature header",
+		},
+		{
+			name:    "unsupported algorithm",
+			modify:  func(r *http.Request) { r.Header.Set("X-Signature-Algorithm", "rsa") },
+			code:    400,
+			message: "Unsupported X-Signature-Algorithm",
+		},
+		{
+			name:    "untrusted algorithm",
+			modify:  func(r *http.Request) { r.Header.Set("X-Signature-Algorithm", "ed448") },
+			code:    401,
+			message: "Request was not signed with a trusted algorithm",
+		},
+		{
+			name:    "missing signed headers",
+			modify:  func(r *http.Request) { r.Header.Del("X-Signed-Headers") },
+			code:    400,
+			message: "Missing X-Signed-Headers header",
+		},
+		{
+			name:    "empty signed headers",
+			modify:  func(r *http.Request) { r.Header.Set("X-Signed-Headers", " ") },
+			code:    400,
+			message: "X-Signed-Headers header lists no headers",
+		},
+		{
+			name:    "missing date",
+			modify:  func(r *http.Request) { r.Header.Del("Date") },
+			code:    400,
+			message: "Unable to read request date",
+		},
+		{
+			name:    "unparseable date",
+			modify:  func(r *http.Request) { r.Header.Set("Date", "Sun, 05 Mar 2017 23:53:08 GMT") },
+			code:    400,
+			message: "Unable to read request date",
+		},
+		{
+			name:    "old request",
+			modify:  func(r *http.Request) { r.Header.Set("Date", "2017-03-05T23:43:08Z") },
+			code:    400,
+			message: "Request time skew is too great",
+		},
+		{
+			name:    "body read error",
+			body:    failingReader{},
+			code:    400,
+			message: "Unable to read request body",
+		},
+		{
+			name:    "unendorsed key",
+			modify:  func(r *http.Request) { r.Header.Set("X-Signature", endorsedByOther) },
+			code:    401,
+			message: "Request Public Key was not endorsed by Manifold",
+		},
+		{
+			name:    "altered body",
+			body:    bytes.NewBufferString("other"),
+			code:    401,
+			message: "Request was not signed by included Public Key",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+			if tc.modify != nil {
+				tc.modify(req)
+			}
+
+			body := tc.body
+			if body == nil {
+				body = bytes.NewBufferString("body")
+			}
+
+			err := v.Verify(req, body)
+			e, ok := err.(*Error)
+			if !ok {
+				t.Fatal("expected *Error, got:", err)
+			}
+
+			rw := httptest.NewRecorder()
+			e.Respond(rw)
+
+			if rw.Code != tc.code {
+				t.Error("unexpected response code:", rw.Code)
+			}
+
+			expected := fmt.Sprintf(`{"message":%q}`, tc.message)
+			if rw.Body.String() != expected {
+				t.Error("unexpected body:", rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("middleware body read error", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Body = ioutil.NopCloser(failingReader{})
+
+		rw := httptest.NewRecorder()
+		v.WrapFunc(func(http.ResponseWriter, *http.Request) {
+			t.Error("expected request not to be passed on")
+		}).ServeHTTP(rw, req)
+
+		if rw.Code != 400 {
+			t.Error("unexpected response code:", rw.Code)
+		}
+
+		if rw.Body.String() != `{"message":"Could not ready body from request"}` {
+			t.Error("unexpected body:", rw.Body.String())
+		}
+	})
+}
+
+func TestParseAndValidateChain(t *testing.T) {
+	keys := newTestKeys()
+	master := keys.master.Public().(ed25519.PublicKey)
+	live := keys.live.Public().(ed25519.PublicKey)
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+
+	chain := func(pub []byte, endorser ed25519.PrivateKey) string {
+		return (&Signature{
+			Value:       base64.New(bytes.Repeat([]byte{1}, ed25519.SignatureSize)),
+			PublicKey:   base64.New(pub),
+			Endorsement: base64.New(ed25519.Sign(endorser, pub)),
+		}).String()
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		pub, err := ParseAndValidateChain(chain(live, keys.master), master)
+		if err != nil {
+			t.Fatal("expected chain to validate, got:", err)
+		}
+
+		if !bytes.Equal(pub, live) {
+			t.Error("unexpected live key:", pub)
+		}
+	})
+
+	tcs := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{"unparseable", "not-a-signature", 400},
+		{"wrong endorser", chain(live, other), 401},
+		{"short live key", chain(live[:16], keys.master), 400},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			pub, err := ParseAndValidateChain(tc.header, master)
+			if pub != nil {
+				t.Error("expected no live key, got:", pub)
+			}
+
+			if e, ok := err.(*Error); !ok || e.Code != tc.code {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+}
+
+func TestVerifyParsed(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+	body := "{\"id\":\"2686c96868emyj61cgt2ma7vdntg4\"}"
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	timeSince = func(rt time.Time) time.Duration {
+		return now.Sub(rt)
+	}
+
+	// parsed returns the signature metadata from req's headers.
+	parsed := func(req *http.Request) (*Signature, []string, time.Time) {
+		sig, _ := ParseSignature(req.Header.Get("X-Signature"))
+		date, _ := time.Parse(time.RFC3339, req.Header.Get("Date"))
+		return sig, strings.Split(req.Header.Get("X-Signed-Headers"), " "), date
+	}
+
+	tcs := []struct {
+		name   string
+		modify func(*http.Request)
+		body   string
+	}{
+		{"valid", nil, body},
+		{"altered body", nil, body + " "},
+		{"altered signed header", func(r *http.Request) { r.Host = "example.com" }, body},
+		{"old request", func(r *http.Request) { r.Header.Set("Date", "2017-03-05T23:43:08Z") }, body},
+		{"other key", func(r *http.Request) {
+			other := &testKeys{live: keys.live, endorsement: ed25519.Sign(keys.live, keys.live.Public().(ed25519.PublicKey))}
+			b, _ := Canonize(r, bytes.NewBufferString(body))
+			other.sign(r, b)
+		}, body},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+			if tc.modify != nil {
+				tc.modify(req)
+			}
+
+			expected := v.Verify(req, bytes.NewBufferString(tc.body))
+
+			sig, signed, date := parsed(req)
+			err := v.VerifyParsed(req, bytes.NewBufferString(tc.body), sig, signed, date)
+
+			if (err == nil) != (expected == nil) {
+				t.Fatalf("expected %v, got %v", expected, err)
+			}
+			if err != nil && err.(*Error).Message != expected.(*Error).Message {
+				t.Errorf("expected %q, got %q", expected, err)
+			}
+		})
+	}
+
+	t.Run("signed headers from argument", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		sig, signed, date := parsed(req)
+		req.Header.Del("X-Signed-Headers")
+
+		if err := v.VerifyParsed(req, bytes.NewBufferString(body), sig, signed, date); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := v.VerifyParsed(req, bytes.NewBufferString(body), sig, signed[:1], date); err == nil {
+			t.Error("expected altered signed headers to fail verification")
+		}
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		_, signed, date := parsed(req)
+
+		err := v.VerifyParsed(req, bytes.NewBufferString(body), nil, signed, date)
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Error("expected missing signature error, got:", err)
+		}
+	})
+}
+
+func TestVerifyDateOffset(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	ots := timeSince
+	defer func() { timeSince = ots }()
+	timeSince = func(rt time.Time) time.Duration {
+		return time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC).Sub(rt)
+	}
+
+	tcs := []struct {
+		name    string
+		date    string
+		success bool
+	}{
+		{"utc", "2017-03-05T23:53:08Z", true},
+		{"positive half hour offset", "2017-03-06T05:23:08+05:30", true},
+		{"negative offset", "2017-03-05T18:53:08-05:00", true},
+		{"zero offset", "2017-03-05T23:53:08+00:00", true},
+		{"offset within window", "2017-03-06T05:26:08+05:30", true},
+		{"offset outside window", "2017-03-06T05:29:08+05:30", false},
+		{"offset misread as utc", "2017-03-05T23:53:08+05:30", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+			req.Header.Set("Date", tc.date)
+			req.Header.Set("X-Signed-Headers", "host date")
+
+			b, err := Canonize(req, &bytes.Buffer{})
+			if err != nil {
+				t.Fatal("could not canonize request:", err)
+			}
+
+			if !bytes.Contains(b, []byte("date: "+tc.date+"\n")) {
+				t.Errorf("expected canonical form to contain literal date %q, got:\n%s", tc.date, b)
+			}
+			keys.sign(req, b)
+
+			err = v.Verify(req, &bytes.Buffer{})
+			if tc.success && err != nil {
+				t.Error("expected request to verify, got:", err)
+			}
+
+			if !tc.success && err == nil {
+				t.Error("expected request to fail verification")
+			}
+		})
+	}
+
+	t.Run("normalized date", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-06T05:23:08+05:30")
+		req.Header.Set("X-Signed-Headers", "host date")
+
+		b, _ := Canonize(req, &bytes.Buffer{})
+		keys.sign(req, b)
+
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		if err := v.Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected date rewritten to UTC to fail verification")
+		}
+	})
+}
+
+func TestSharedVerifier(t *testing.T) {
+	keys := newTestKeys()
+
+	// A single Verifier shared by concurrent requests enforces its use limit
+	// across all of them.
+	v := keys.verifier(t, WithSignatureUseLimit(1))
+
+	reqs := make([]*http.Request, 16)
+	for i := range reqs {
+		reqs[i] = keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+	}
+
+	var verified int32
+	done := make(chan struct{})
+	for _, req := range reqs {
+		go func(req *http.Request) {
+			if v.Verify(req, bytes.NewBufferString("body")) == nil {
+				atomic.AddInt32(&verified, 1)
+			}
+			done <- struct{}{}
+		}(req)
+	}
+	for range reqs {
+		<-done
+	}
+
+	if verified != 1 {
+		t.Errorf("expected the signature to verify once, got %d", verified)
+	}
+}
+
+func TestNewVerifierPool(t *testing.T) {
+	keys := newTestKeys()
+	dummyKey := "PY7wu3q3-adYr9-0ES6CMRixup9OjO5iL7EFDFpolhk"
+
+	verify := func(v *Verifier) error {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		return v.Verify(req, bytes.NewBufferString("body"))
+	}
+
+	t.Run("old key", func(t *testing.T) {
+		v, err := NewVerifierPool([]string{keys.masterKey(), dummyKey})
+		if err != nil {
+			t.Fatal("could not create verifier:", err)
+		}
+
+		if err := verify(v); err != nil {
+			t.Error("expected request to verify, got:", err)
+		}
+	})
+
+	t.Run("new key", func(t *testing.T) {
+		v, err := NewVerifierPool([]string{dummyKey, keys.masterKey()})
+		if err != nil {
+			t.Fatal("could not create verifier:", err)
+		}
+
+		if err := verify(v); err != nil {
+			t.Error("expected request to verify, got:", err)
+		}
+	})
+
+	t.Run("no endorsing key", func(t *testing.T) {
+		other := base64.New(newTestKeys().live.Public().(ed25519.PublicKey)).String()
+		v, err := NewVerifierPool([]string{dummyKey, other})
+		if err != nil {
+			t.Fatal("could not create verifier:", err)
+		}
+
+		err = verify(v)
+		if e, ok := err.(*Error); !ok || e.Code != 401 || e.Message != "Request Public Key was not endorsed by any trusted master key" {
+			t.Error("expected endorsement error, got:", err)
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		if _, err := NewVerifierPool(nil); err != ErrInvalidPublicKey {
+			t.Error("expected no keys to be invalid, got:", err)
+		}
+
+		if _, err := NewVerifierPool([]string{dummyKey, "not a key"}); err != ErrInvalidPublicKey {
+			t.Error("expected invalid key to be rejected, got:", err)
+		}
+	})
+}
+
+func TestCanonizeQuery(t *testing.T) {
+	tcs := []struct {
+		name    string
+		query   string
+		raw     string
+		decoded string
+	}{
+		{"sorted", "b=2&a=1", "a=1&b=2", "a=1&b=2"},
+		{"plus as space", "q=a+b", "q=a+b", "q=a%20b"},
+		{"encoded space", "q=a%20b", "q=a%20b", "q=a%20b"},
+		{"encoded plus", "q=a%2Bb", "q=a%2Bb", "q=a%2Bb"},
+		{"lowercase escapes", "q=%c3%a9", "q=%c3%a9", "q=%C3%A9"},
+		{"unreserved escaped", "q=%61%2D%7E", "q=%61%2D%7E", "q=a-~"},
+		{"reserved unescaped", "redirect=/v1/x?y", "redirect=/v1/x?y", "redirect=%2Fv1%2Fx%3Fy"},
+		{"sorted by name", "a%20b=1&a=2&a-b=3", "a%20b=1&a-b=3&a=2", "a=2&a%20b=1&a-b=3"},
+		{"repeated names", "tag=z&tag=x&tag=y", "tag=x&tag=y&tag=z", "tag=x&tag=y&tag=z"},
+		{"repeated names among others", "b=2&a=1&a=0", "a=0&a=1&b=2", "a=0&a=1&b=2"},
+		{"repeated names, prefix values", "id=10&id=1&id=", "id=&id=1&id=10", "id=&id=1&id=10"},
+		{"repeated names, mixed encoding", "tag=b+c&tag=a&tag=b%20b", "tag=a&tag=b%20b&tag=b+c", "tag=a&tag=b%20b&tag=b%20c"},
+		{"no value", "flag&a=1", "a=1&flag", "a=1&flag"},
+		{"empty value", "flag=&a=1", "a=1&flag=", "a=1&flag="},
+		{"undecodable", "b=%zz&a=1", "a=1&b=%zz", "a=1&b=%zz"},
+	}
+
+	target := func(t *testing.T, canon *canonOptions, query string) string {
+		req, _ := http.NewRequest("GET", "/v1/resources", nil)
+		req.URL.RawQuery = query
+		req.Header.Set("X-Signed-Headers", "date")
+
+		b, err := canon.canonize(req, &bytes.Buffer{})
+		if err != nil {
+			t.Fatal("could not canonize request:", err)
+		}
+
+		return strings.SplitN(string(b), "\n", 2)[0]
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := target(t, &canonOptions{}, tc.query); got != "get /v1/resources?"+tc.raw {
+				t.Errorf("unexpected target: %q", got)
+			}
+
+			if got := target(t, &canonOptions{decodedQuery: true}, tc.query); got != "get /v1/resources?"+tc.decoded {
+				t.Errorf("unexpected decoded target: %q", got)
+			}
+		})
+	}
+
+	t.Run("repeated names in any order", func(t *testing.T) {
+		keys := newTestKeys()
+		req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources?b=2&a=1&a=0", "")
+
+		for _, q := range []string{"a=0&a=1&b=2", "a=1&b=2&a=0", "b=2&a=0&a=1"} {
+			req.URL.RawQuery = q
+			if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err != nil {
+				t.Errorf("expected query %q to verify, got: %s", q, err)
+			}
+		}
+	})
+}
+
+// cancellingReader reads one byte at a time from r, calling cancel after the
+// first read, as a client hanging mid-body might be abandoned.
+type cancellingReader struct {
+	r      io.Reader
+	cancel func()
+}
+
+func (c *cancellingReader) Read(p []byte) (int, error) {
+	defer c.cancel()
+	return c.r.Read(p[:1])
+}
+
+// gatedReader reads a byte at a time from r, with each read blocking until wait
+// is closed, and counts its reads.
+type gatedReader struct {
+	r     io.Reader
+	wait  <-chan struct{}
+	reads int32
+}
+
+func (g *gatedReader) Read(p []byte) (int, error) {
+	<-g.wait
+	atomic.AddInt32(&g.reads, 1)
+	return g.r.Read(p[:1])
+}
+
+// ctxReader reads from r, with each read blocking until ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	<-c.ctx.Done()
+	return c.r.Read(p)
+}
+
+func TestCanonizeRepeatedSignedHeaders(t *testing.T) {
+	keys := newTestKeys()
+
+	tcs := []struct {
+		name          string
+		signedHeaders string
+		expected      string
+	}{
+		{
+			"mixed case", "host Content-Type Content-type",
+			"host: 127.0.0.1:4567\n" +
+				"content-type: application/json\n" +
+				"x-signed-headers: host Content-Type Content-type\n",
+		},
+		{
+			"repeated", "date host date",
+			"date: 2017-03-05T23:53:08Z\n" +
+				"host: 127.0.0.1:4567\n" +
+				"x-signed-headers: date host date\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+			req.Header.Set("Date", "2017-03-05T23:53:08Z")
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Signed-Headers", tc.signedHeaders)
+
+			b, err := Canonize(req, &bytes.Buffer{})
+			if err != nil {
+				t.Fatal("could not canonize request:", err)
+			}
+
+			if headers := strings.SplitN(string(b), "\n", 2)[1]; headers != tc.expected {
+				t.Errorf("unexpected canonical headers:\n%s", headers)
+			}
+
+			keys.sign(req, b)
+			if err := keys.verifier(t).Verify(req, &bytes.Buffer{}); err != nil {
+				t.Error("expected request to verify, got:", err)
+			}
+		})
+	}
+
+	t.Run("lowercase names", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "Date host date")
+
+		v := keys.verifier(t, WithLowercaseHeaderNames())
+		b, _ := v.canon.canonize(req, &bytes.Buffer{})
+		if !bytes.HasSuffix(b, []byte("x-signed-headers: date host\n")) {
+			t.Errorf("expected de-duplicated signed headers list, got:\n%s", b)
+		}
+	})
+}
+
+func TestVerifyContext(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t, signedAt)
+
+	t.Run("valid", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		if err := v.VerifyContext(context.Background(), req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("cancelled mid-body", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		ctx, cancel := context.WithCancel(context.Background())
+		body := &cancellingReader{r: bytes.NewBufferString("body"), cancel: cancel}
+
+		err := v.VerifyContext(ctx, req, body)
+		if e, ok := err.(*Error); !ok || e.Code != http.StatusRequestTimeout {
+			t.Error("expected a 408 Error, got:", err)
+		}
+
+		if !errors.Is(err, context.Canceled) {
+			t.Error("expected error to wrap context.Canceled, got:", err)
+		}
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		if err := v.VerifyContext(ctx, req, bytes.NewBufferString("body")); !errors.Is(err, context.DeadlineExceeded) {
+			t.Error("expected error to wrap context.DeadlineExceeded, got:", err)
+		}
+	})
+
+	t.Run("verify timeout", func(t *testing.T) {
+		v := keys.verifier(t, WithVerifyTimeout(10*time.Millisecond), signedAt)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+
+		wait := make(chan struct{})
+		body := &gatedReader{r: bytes.NewBufferString("body"), wait: wait}
+
+		err := v.VerifyContext(context.Background(), req, body)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Error("expected error to wrap context.DeadlineExceeded, got:", err)
+		}
+
+		// The read in progress at the timeout completes, but no more follow.
+		close(wait)
+		time.Sleep(20 * time.Millisecond)
+		if n := atomic.LoadInt32(&body.reads); n != 1 {
+			t.Errorf("expected reading to stop after the timeout, got %d reads", n)
+		}
+	})
+
+	t.Run("request context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body").WithContext(ctx)
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); !errors.Is(err, context.Canceled) {
+			t.Error("expected Verify to use the request context, got:", err)
+		}
+	})
+
+	t.Run("cancelled during read", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		err := v.VerifyContext(ctx, req, &ctxReader{ctx: ctx, r: bytes.NewBufferString("body")})
+		if e, ok := err.(*Error); !ok || e.Code != http.StatusRequestTimeout || !errors.Is(err, context.Canceled) {
+			t.Error("expected a 408 Error wrapping context.Canceled, got:", err)
+		}
+	})
+
+	t.Run("cancelled during blocked read", func(t *testing.T) {
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		// The read never returns, so verification must be abandoned.
+		stalled, release := context.WithCancel(context.Background())
+		defer release()
+
+		err := v.VerifyContext(ctx, req, &ctxReader{ctx: stalled, r: bytes.NewBufferString("body")})
+		if !errors.Is(err, context.Canceled) {
+			t.Error("expected error to wrap context.Canceled, got:", err)
+		}
+	})
+}
+
+func TestSignatureComponentLengths(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	valid := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+	sig, err := ParseSignature(valid.Header.Get("X-Signature"))
+	if err != nil {
+		t.Fatal("could not parse signature:", err)
+	}
+
+	resize := func(b []byte, n int) *base64.Value {
+		if n <= len(b) {
+			return base64.New(b[:n])
+		}
+		return base64.New(append(append([]byte(nil), b...), make([]byte, n-len(b))...))
+	}
+
+	tcs := []struct {
+		name   string
+		sig    *Signature
+		parses bool
+	}{
+		{"truncated value", &Signature{Value: resize(*sig.Value, 63), PublicKey: sig.PublicKey, Endorsement: sig.Endorsement}, false},
+		{"oversized value", &Signature{Value: resize(*sig.Value, 65), PublicKey: sig.PublicKey, Endorsement: sig.Endorsement}, false},
+		{"truncated public key", &Signature{Value: sig.Value, PublicKey: resize(*sig.PublicKey, 31), Endorsement: sig.Endorsement}, false},
+		{"oversized public key", &Signature{Value: sig.Value, PublicKey: resize(*sig.PublicKey, 33), Endorsement: sig.Endorsement}, false},
+		{"empty public key", &Signature{Value: sig.Value, PublicKey: base64.New([]byte{}), Endorsement: sig.Endorsement}, false},
+		{"truncated endorsement", &Signature{Value: sig.Value, PublicKey: sig.PublicKey, Endorsement: resize(*sig.Endorsement, 32)}, true},
+		{"oversized endorsement", &Signature{Value: sig.Value, PublicKey: sig.PublicKey, Endorsement: resize(*sig.Endorsement, 128)}, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSignature(tc.sig.String())
+			if tc.parses && err != nil {
+				t.Error("expected signature to parse, got:", err)
+			}
+			if !tc.parses {
+				if e, ok := err.(*Error); !ok || e.Code != 400 {
+					t.Error("expected a 400 Error, got:", err)
+				}
+			}
+
+			req := keys.newSignedReq(t, "GET", "https://127.0.0.1:4567/v1/resources", "")
+			req.Header.Set("X-Signature", tc.sig.String())
+
+			err = v.Verify(req, &bytes.Buffer{})
+			if e, ok := err.(*Error); !ok || e.Code != 400 || e.Message != "X-Signature components have invalid lengths" {
+				t.Error("expected invalid lengths error, got:", err)
+			}
+		})
+	}
+}
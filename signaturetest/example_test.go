@@ -0,0 +1,49 @@
+package signaturetest_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+	"github.com/manifoldco/go-signature"
+	"github.com/manifoldco/go-signature/signaturetest"
+)
+
+func Example() {
+	master := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	live := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+
+	v, _ := signature.NewVerifier(base64.New(master.Public().(ed25519.PublicKey)).String())
+	srv := signaturetest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		fmt.Fprintf(rw, "%s %q", req.Method, b)
+	}), v)
+	defer srv.Close()
+
+	endorsement := ed25519.Sign(master, live.Public().(ed25519.PublicKey))
+	client := signaturetest.Client(signature.NewSigner(live, base64.New(endorsement)))
+
+	resp, _ := client.Get(srv.URL + "/v1/resources")
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	fmt.Println(resp.StatusCode, string(b))
+
+	resp, _ = client.Post(srv.URL+"/v1/resources", "application/json", strings.NewReader(`{"plan":"low"}`))
+	b, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	fmt.Println(resp.StatusCode, string(b))
+
+	resp, _ = http.Get(srv.URL + "/v1/resources")
+	resp.Body.Close()
+	fmt.Println(resp.StatusCode)
+
+	// Output:
+	// 200 GET ""
+	// 200 POST "{\"plan\":\"low\"}"
+	// 400
+}
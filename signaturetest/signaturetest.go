@@ -0,0 +1,23 @@
+// Package signaturetest provides utilities for testing services that verify
+// signed requests, by sending signed requests to a verifying test server.
+package signaturetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/manifoldco/go-signature"
+)
+
+// NewServer starts and returns a new test server, serving handler behind
+// verification by v. The caller should call Close when finished, to shut it
+// down.
+func NewServer(handler http.Handler, v *signature.Verifier) *httptest.Server {
+	return httptest.NewServer(v.Wrap(handler))
+}
+
+// Client returns an HTTP client that signs each request it sends with signer,
+// using a signature.SigningTransport.
+func Client(signer signature.RequestSigner) *http.Client {
+	return &http.Client{Transport: &signature.SigningTransport{Signer: signer}}
+}
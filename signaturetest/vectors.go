@@ -0,0 +1,166 @@
+package signaturetest
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+	"github.com/manifoldco/go-signature"
+)
+
+// Vector is a signed request, with its canonical form and signature, for
+// validating other implementations of request signing. Vectors serialize to
+// JSON.
+type Vector struct {
+	// MasterKey is the master public key that endorsed the signing key, as
+	// given to signature.NewVerifier.
+	MasterKey string `json:"master_key"`
+
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+
+	// Canonical is the canonical form of the request, as built by
+	// signature.Canonize.
+	Canonical string `json:"canonical"`
+
+	// Signature is the request's X-Signature header value.
+	Signature string `json:"signature"`
+}
+
+// Request reconstructs the vector's signed request.
+func (v *Vector) Request() (*http.Request, error) {
+	ev := signature.WebhookEvent{Method: v.Method, URL: v.URL, Header: v.Header, Body: []byte(v.Body)}
+	return ev.Request()
+}
+
+// GenerateVectors returns n signed requests with random methods, paths, query
+// parameters, headers and bodies. The requests are signed by a
+// signature.Signer with a random live key, endorsed by a random master key.
+//
+// Keys and requests are drawn from a source seeded with seed, and each
+// request's Date header is set to now, so the same arguments always return the
+// same vectors. Verifiers must check the vectors with a clock near now, such
+// as one set by signature.WithClock.
+func GenerateVectors(seed int64, now time.Time, n int) ([]Vector, error) {
+	r := mrand.New(mrand.NewSource(seed))
+
+	master, err := randomKey(r)
+	if err != nil {
+		return nil, err
+	}
+	live, err := randomKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	livePub := live.Public().(ed25519.PublicKey)
+	s := signature.NewSigner(live, base64.New(ed25519.Sign(master, livePub)))
+	masterKey := base64.New(master.Public().(ed25519.PublicKey)).String()
+	date := now.UTC().Format(time.RFC3339)
+
+	vectors := make([]Vector, n)
+	for i := range vectors {
+		req, body := randomRequest(r)
+		req.Header.Set("Date", date)
+		if err := s.Sign(req, strings.NewReader(body)); err != nil {
+			return nil, err
+		}
+
+		b, err := signature.Canonize(req, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		vectors[i] = Vector{
+			MasterKey: masterKey,
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Header:    req.Header,
+			Body:      body,
+			Canonical: string(b),
+			Signature: req.Header.Get("X-Signature"),
+		}
+	}
+
+	return vectors, nil
+}
+
+// randomKey returns an Ed25519 private key, generated from a seed read from r.
+func randomKey(r *mrand.Rand) (ed25519.PrivateKey, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := r.Read(seed); err != nil {
+		return nil, err
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+const vectorChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.~ /&=+%"
+
+// randomString returns a string of up to max characters, drawn from chars.
+func randomString(r *mrand.Rand, chars string, max int) string {
+	b := make([]byte, r.Intn(max+1))
+	for i := range b {
+		b[i] = chars[r.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// randomRequest returns a random unsigned request and its body.
+func randomRequest(r *mrand.Rand) (*http.Request, string) {
+	methods := []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	method := methods[r.Intn(len(methods))]
+
+	segments := make([]string, 1+r.Intn(3))
+	for i := range segments {
+		segments[i] = url.PathEscape(randomString(r, vectorChars[:62], 8) + "x")
+	}
+
+	var query []string
+	for i := r.Intn(4); i > 0; i-- {
+		name := randomString(r, vectorChars[:26], 4) + "q"
+		value := strings.Replace(url.QueryEscape(randomString(r, vectorChars, 8)), "+", "%20", -1)
+		query = append(query, name+"="+value)
+	}
+
+	u := &url.URL{
+		Scheme:   "https",
+		Host:     "127.0.0.1:" + strconv.Itoa(1024+r.Intn(60000)),
+		Path:     "/" + strings.Join(segments, "/"),
+		RawQuery: strings.Join(query, "&"),
+	}
+
+	var body string
+	if method != "GET" && method != "HEAD" {
+		body = randomString(r, vectorChars, 64)
+	}
+
+	req, _ := http.NewRequest(method, u.String(), bytes.NewBufferString(body))
+
+	signed := []string{"host", "date"}
+	if body != "" {
+		req.Header.Set("Content-Type", "text/plain")
+		signed = append(signed, "content-type")
+	}
+	for i := r.Intn(3); i > 0; i-- {
+		name := "X-Vector-" + strconv.Itoa(i)
+		for j := 1 + r.Intn(2); j > 0; j-- {
+			req.Header.Add(name, randomString(r, vectorChars[:62], 12))
+		}
+		if r.Intn(2) == 0 {
+			signed = append(signed, strings.ToLower(name))
+		}
+	}
+	req.Header.Set("X-Signed-Headers", strings.Join(signed, " "))
+
+	return req, body
+}
@@ -0,0 +1,73 @@
+package signaturetest_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manifoldco/go-signature"
+	"github.com/manifoldco/go-signature/signaturetest"
+)
+
+func TestGenerateVectors(t *testing.T) {
+	now := time.Date(2017, 3, 5, 23, 53, 8, 0, time.UTC)
+	vectors, err := signaturetest.GenerateVectors(1, now, 50)
+	if err != nil {
+		t.Fatal("could not generate vectors:", err)
+	}
+	if len(vectors) != 50 {
+		t.Fatalf("expected 50 vectors, got %d", len(vectors))
+	}
+
+	b, err := json.Marshal(vectors)
+	if err != nil {
+		t.Fatal("could not encode vectors:", err)
+	}
+
+	var decoded []signaturetest.Vector
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal("could not decode vectors:", err)
+	}
+
+	v, err := signature.NewVerifier(decoded[0].MasterKey, signature.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal("could not create verifier:", err)
+	}
+
+	for i, vec := range decoded {
+		req, err := vec.Request()
+		if err != nil {
+			t.Fatalf("vector %d: could not build request: %s", i, err)
+		}
+
+		b, err := v.VerifyReturningCanonical(req, strings.NewReader(vec.Body))
+		if err != nil {
+			t.Errorf("vector %d: expected %s %s to verify, got: %s", i, vec.Method, vec.URL, err)
+			continue
+		}
+
+		if string(b) != vec.Canonical {
+			t.Errorf("vector %d: canonical form mismatch:\nexpected %q\ngot      %q", i, vec.Canonical, b)
+		}
+	}
+
+	t.Run("reproducible", func(t *testing.T) {
+		again, err := signaturetest.GenerateVectors(1, now, 50)
+		if err != nil {
+			t.Fatal("could not generate vectors:", err)
+		}
+		if !reflect.DeepEqual(vectors, again) {
+			t.Error("expected the same seed and time to generate the same vectors")
+		}
+
+		other, err := signaturetest.GenerateVectors(2, now, 50)
+		if err != nil {
+			t.Fatal("could not generate vectors:", err)
+		}
+		if other[0].MasterKey == vectors[0].MasterKey {
+			t.Error("expected a different seed to generate different keys")
+		}
+	})
+}
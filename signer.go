@@ -0,0 +1,175 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+// signNow is replaced during testing
+var signNow = time.Now
+
+// DefaultSignedHeaders are the headers signed by a Signer when the request
+// does not list its own in an X-Signed-Headers header.
+const DefaultSignedHeaders = "host date"
+
+// ErrSignerKeyType is returned from Signer.Sign when the Signer's key is not
+// an Ed25519 key.
+var ErrSignerKeyType = errors.New("The signing key is not an Ed25519 key")
+
+// Signer signs outgoing requests with a live key, producing signatures that a
+// Verifier trusting the live key's endorser accepts.
+type Signer struct {
+	key         crypto.Signer
+	pub         *base64.Value
+	endorsement *base64.Value
+}
+
+// NewSigner returns a new Signer, signing with the live private key
+// privateKey. endorsement is the master key's signature of the live public
+// key, as checked by Signature.Validate.
+//
+// privateKey is typically an ed25519.PrivateKey, but may be any crypto.Signer
+// with an Ed25519 public key, such as one backed by an HSM or KMS. Its Sign
+// method is called with crypto.Hash(0), as for an ed25519.PrivateKey.
+func NewSigner(privateKey crypto.Signer, endorsement *base64.Value) *Signer {
+	s := &Signer{key: privateKey, endorsement: endorsement}
+	if pub, ok := privateKey.Public().(ed25519.PublicKey); ok {
+		s.pub = base64.New(pub)
+	}
+
+	return s
+}
+
+// Sign signs req, setting its X-Signature header to the signature of its
+// canonical form, built by Canonize with the given body. req is modified in
+// place.
+//
+// If req has no Date header, it is set to the current time. If req has no
+// X-Signed-Headers header, it is set to DefaultSignedHeaders.
+//
+// Sign returns any error from the Signer's key, and ErrSignerKeyType if the key
+// is not an Ed25519 key.
+//
+// The request body is not read directly, instead, body is read, allowing
+// buffering or duplication of the body to be handled outside of this method.
+func (s *Signer) Sign(req *http.Request, body io.Reader) error {
+	if s.pub == nil {
+		return ErrSignerKeyType
+	}
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", signNow().UTC().Format(time.RFC3339))
+	}
+
+	if _, ok := req.Header["X-Signed-Headers"]; !ok {
+		req.Header.Set("X-Signed-Headers", DefaultSignedHeaders)
+	}
+
+	b, err := Canonize(req, body)
+	if err != nil {
+		return err
+	}
+
+	v, err := s.key.Sign(rand.Reader, b, crypto.Hash(0))
+	if err != nil {
+		return err
+	}
+
+	sig := &Signature{
+		Value:       base64.New(v),
+		PublicKey:   s.pub,
+		Endorsement: s.endorsement,
+	}
+	req.Header.Set("X-Signature", sig.String())
+	return nil
+}
+
+// VerifyAndResign verifies req with v, then replaces its signature with a new
+// one from s, for gateways that forward verified requests to services trusting
+// the gateway's own key. The Date header is updated to the current time, and
+// the headers listed in the request's signature are signed again.
+//
+// All of the incoming signature data is removed before re-signing: the
+// X-Signature and X-Signature-Algorithm headers, the split X-Signature-Value,
+// X-Signature-Key and X-Signature-Endorsement headers, and a Manifold
+// Authorization header.
+//
+// The request body is read in full, up to the limit set by WithMaxBodySize,
+// and replaced so that it can be read again when the request is forwarded.
+// req is not re-signed if it fails verification.
+func VerifyAndResign(req *http.Request, v *Verifier, s *Signer) error {
+	var body []byte
+	if req.Body != nil {
+		var r io.Reader = req.Body
+		if v.maxBodySize > 0 {
+			r = limitBody(r, v.maxBodySize)
+		}
+
+		var err error
+		body, err = ioutil.ReadAll(r)
+		req.Body.Close()
+		if err != nil {
+			if e, ok := err.(*Error); ok {
+				return e
+			}
+			return &Error{Code: 400, Message: "Unable to read request body", category: Malformed}
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := v.Verify(req, bytes.NewReader(body)); err != nil {
+		return err
+	}
+
+	// The request verified, so any packed signature data unpacks cleanly.
+	signed := req
+	if v.authorizationHeader {
+		signed, _ = unpackAuthorization(signed)
+	}
+	if v.combinedSignature {
+		signed, _ = unpackCombinedSignature(signed)
+	}
+	names := signed.Header.Get("X-Signed-Headers")
+
+	stripSignature(req.Header)
+	req.Header.Set("X-Signed-Headers", names)
+	req.Header.Del("Date")
+	return s.Sign(req, bytes.NewReader(body))
+}
+
+// signatureHeaders are the headers that may carry signature data.
+var signatureHeaders = []string{
+	"X-Signature",
+	"X-Signature-Algorithm",
+	"X-Signature-Value",
+	"X-Signature-Key",
+	"X-Signature-Endorsement",
+}
+
+// stripSignature removes all signature data from h, including a Manifold
+// Authorization header.
+func stripSignature(h http.Header) {
+	for _, name := range signatureHeaders {
+		h.Del(name)
+	}
+
+	auth := h.Get("Authorization")
+	if i := strings.IndexByte(auth, ' '); i >= 0 && strings.EqualFold(auth[:i], authorizationScheme) {
+		h.Del("Authorization")
+	}
+}
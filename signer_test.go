@@ -0,0 +1,268 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/manifoldco/go-base64"
+)
+
+func TestSigner(t *testing.T) {
+	keys := newTestKeys()
+	signer := NewSigner(keys.live, base64.New(keys.endorsement))
+	v := keys.verifier(t)
+
+	osn := signNow
+	defer func() { signNow = osn }()
+	signNow = func() time.Time {
+		return time.Date(2017, 3, 5, 23, 53, 8, 0, time.FixedZone("EST", -5*60*60))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		if err := signer.Sign(req, bytes.NewBufferString("body")); err != nil {
+			t.Fatal("could not sign request:", err)
+		}
+
+		if d := req.Header.Get("Date"); d != "2017-03-06T04:53:08Z" {
+			t.Error("unexpected date:", d)
+		}
+
+		if sh := req.Header.Get("X-Signed-Headers"); sh != DefaultSignedHeaders {
+			t.Error("unexpected signed headers:", sh)
+		}
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		if err := v.Verify(req, bytes.NewBufferString("other")); err == nil {
+			t.Error("expected altered body to fail verification")
+		}
+	})
+
+	t.Run("existing headers", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Signed-Headers", "host date content-type")
+
+		if err := signer.Sign(req, bytes.NewBufferString("{}")); err != nil {
+			t.Fatal("could not sign request:", err)
+		}
+
+		if d := req.Header.Get("Date"); d != "2017-03-05T23:53:08Z" {
+			t.Error("expected date to be kept, got:", d)
+		}
+
+		if err := v.Verify(req, bytes.NewBufferString("{}")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+
+		req.Header.Set("Content-Type", "text/plain")
+		if err := v.Verify(req, bytes.NewBufferString("{}")); err == nil {
+			t.Error("expected altered signed header to fail verification")
+		}
+	})
+
+	t.Run("untrusted endorsement", func(t *testing.T) {
+		signer := NewSigner(keys.live, base64.New(make([]byte, 64)))
+
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		if err := signer.Sign(req, &bytes.Buffer{}); err != nil {
+			t.Fatal("could not sign request:", err)
+		}
+
+		if err := v.Verify(req, &bytes.Buffer{}); err == nil {
+			t.Error("expected unendorsed key to fail verification")
+		}
+	})
+}
+
+// recordingSigner is a crypto.Signer that records the messages it signs,
+// delegating to key, as an HSM or KMS backed key might.
+type recordingSigner struct {
+	key    ed25519.PrivateKey
+	signed [][]byte
+	err    error
+}
+
+func (r *recordingSigner) Public() crypto.PublicKey {
+	return r.key.Public()
+}
+
+func (r *recordingSigner) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("unexpected hash")
+	}
+	r.signed = append(r.signed, msg)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return r.key.Sign(rand, msg, opts)
+}
+
+func TestSignerCryptoSigner(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	t.Run("delegated", func(t *testing.T) {
+		rs := &recordingSigner{key: keys.live}
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", nil)
+		if err := NewSigner(rs, base64.New(keys.endorsement)).Sign(req, bytes.NewBufferString("body")); err != nil {
+			t.Fatal("could not sign request:", err)
+		}
+
+		expected, _ := Canonize(req, bytes.NewBufferString("body"))
+		if len(rs.signed) != 1 || !bytes.Equal(rs.signed[0], expected) {
+			t.Errorf("expected canonical form to be signed once, got: %q", rs.signed)
+		}
+
+		if err := v.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected signature to verify, got:", err)
+		}
+	})
+
+	t.Run("signing error", func(t *testing.T) {
+		rs := &recordingSigner{key: keys.live, err: errors.New("kms unavailable")}
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		if err := NewSigner(rs, base64.New(keys.endorsement)).Sign(req, &bytes.Buffer{}); err != rs.err {
+			t.Error("expected signing error, got:", err)
+		}
+
+		if req.Header.Get("X-Signature") != "" {
+			t.Error("expected no signature to be set")
+		}
+	})
+
+	t.Run("wrong key type", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, _ := http.NewRequest("GET", "https://127.0.0.1:4567/v1/resources", nil)
+		if err := NewSigner(key, base64.New(keys.endorsement)).Sign(req, &bytes.Buffer{}); err != ErrSignerKeyType {
+			t.Error("expected key type error, got:", err)
+		}
+	})
+}
+
+func TestVerifyAndResign(t *testing.T) {
+	incoming := newTestKeys()
+	v := incoming.verifier(t)
+
+	gateway := &testKeys{
+		master: ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize)),
+		live:   ed25519.NewKeyFromSeed(bytes.Repeat([]byte{4}, ed25519.SeedSize)),
+	}
+	gateway.endorsement = ed25519.Sign(gateway.master, gateway.live.Public().(ed25519.PublicKey))
+	signer := NewSigner(gateway.live, base64.New(gateway.endorsement))
+	downstream := gateway.verifier(t)
+
+	t.Run("valid", func(t *testing.T) {
+		req := incoming.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		original := req.Header.Get("X-Signature")
+
+		if err := VerifyAndResign(req, v, signer); err != nil {
+			t.Fatal("expected request to verify, got:", err)
+		}
+
+		if req.Header.Get("X-Signature") == original {
+			t.Error("expected request to be re-signed")
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		if string(body) != "body" {
+			t.Errorf("expected body to be preserved, got %q", body)
+		}
+
+		if err := downstream.Verify(req, bytes.NewReader(body)); err != nil {
+			t.Error("expected re-signed request to verify, got:", err)
+		}
+
+		if err := v.Verify(req, bytes.NewReader(body)); err == nil {
+			t.Error("expected re-signed request not to verify with the incoming key")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		req := incoming.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Body = ioutil.NopCloser(bytes.NewBufferString("altered"))
+		original := req.Header.Get("X-Signature")
+
+		if err := VerifyAndResign(req, v, signer); err == nil {
+			t.Fatal("expected altered request to fail verification")
+		}
+
+		if req.Header.Get("X-Signature") != original {
+			t.Error("expected unverified request not to be re-signed")
+		}
+	})
+	t.Run("packed signature", func(t *testing.T) {
+		req := incoming.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Header.Set("Authorization", `Manifold date="`+req.Header.Get("Date")+
+			`", headers="`+req.Header.Get("X-Signed-Headers")+
+			`", sig="`+req.Header.Get("X-Signature")+`"`)
+		req.Header.Del("Date")
+		req.Header.Del("X-Signed-Headers")
+		req.Header.Del("X-Signature")
+		req.Header.Set("X-Signature-Algorithm", "ed25519")
+
+		if err := VerifyAndResign(req, incoming.verifier(t, WithAuthorizationHeader()), signer); err != nil {
+			t.Fatal("expected request to verify, got:", err)
+		}
+
+		for _, name := range []string{"Authorization", "X-Signature-Algorithm"} {
+			if _, ok := req.Header[name]; ok {
+				t.Errorf("expected %s header to be removed", name)
+			}
+		}
+
+		if h := req.Header.Get("X-Signed-Headers"); h != "host date" {
+			t.Errorf("expected signed headers to be preserved, got %q", h)
+		}
+
+		if err := downstream.Verify(req, bytes.NewBufferString("body")); err != nil {
+			t.Error("expected re-signed request to verify, got:", err)
+		}
+	})
+
+	t.Run("split signature", func(t *testing.T) {
+		req := incoming.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+		req.Header.Set("X-Signature-Value", "value")
+		req.Header.Set("X-Signature-Key", "key")
+		req.Header.Set("X-Signature-Endorsement", "endorsement")
+
+		if err := VerifyAndResign(req, v, signer); err != nil {
+			t.Fatal("expected request to verify, got:", err)
+		}
+
+		for _, name := range []string{"X-Signature-Value", "X-Signature-Key", "X-Signature-Endorsement"} {
+			if _, ok := req.Header[name]; ok {
+				t.Errorf("expected %s header to be removed", name)
+			}
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		req := incoming.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", "body")
+
+		err := VerifyAndResign(req, incoming.verifier(t, WithMaxBodySize(3)), signer)
+		if e, ok := err.(*Error); !ok || e.Code != 413 {
+			t.Fatal("expected oversized body to be rejected, got:", err)
+		}
+	})
+}
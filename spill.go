@@ -0,0 +1,91 @@
+package signature
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// WithSpillToDisk configures the middleware to buffer request bodies larger
+// than threshold bytes to a temporary file in dir, rather than in memory. If
+// dir is empty, the default temporary directory is used.
+//
+// Bodies buffered to disk are verified as they are read back from the file,
+// without being held in memory. Options that need the whole body to build the
+// canonical form, such as WithBase64Body, WithBodyTransforms,
+// WithLengthPrefixedBody or WithCertificateEndorsement, and Ed448 signatures,
+// still read it back into memory to verify it.
+//
+// The handler receives a file-backed request body, which is removed when the
+// body is closed, or when the handler returns. Bodies buffered to disk are not
+// sent to a channel configured with WithBodyTap.
+func WithSpillToDisk(threshold int64, dir string) Option {
+	return func(v *Verifier) {
+		v.spillThreshold = threshold
+		v.spillDir = dir
+	}
+}
+
+// spillFile is a request body buffered to a temporary file. The file is
+// removed when it is closed.
+type spillFile struct {
+	*os.File
+	size int64
+	once sync.Once
+}
+
+// Close closes and removes the file. It is safe to call more than once.
+func (f *spillFile) Close() error {
+	var err error
+	f.once.Do(func() {
+		err = f.File.Close()
+		if rerr := os.Remove(f.Name()); err == nil {
+			err = rerr
+		}
+	})
+
+	return err
+}
+
+// readBody reads r in full. If the Verifier spills to disk, and r is larger
+// than the threshold, it is written to a spillFile, positioned at its start.
+// Otherwise, its contents are returned.
+//
+// If the Verifier has a maximum body size, and r is larger, an Error is
+// returned once the limit is exceeded.
+func (v *Verifier) readBody(r io.Reader) ([]byte, *spillFile, error) {
+	if v.maxBodySize > 0 {
+		r = limitBody(r, v.maxBodySize)
+	}
+
+	if v.spillThreshold <= 0 {
+		b := &bytes.Buffer{}
+		_, err := b.ReadFrom(r)
+		return b.Bytes(), nil, err
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, v.spillThreshold+1))
+	if err != nil || int64(len(b)) <= v.spillThreshold {
+		return b, nil, err
+	}
+
+	f, err := ioutil.TempFile(v.spillDir, "signature-body-")
+	if err != nil {
+		return nil, nil, err
+	}
+	sf := &spillFile{File: f}
+
+	n, err := io.Copy(f, io.MultiReader(bytes.NewReader(b), r))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		sf.Close() // nolint: errcheck
+		return nil, nil, err
+	}
+
+	sf.size = n
+	return nil, sf, nil
+}
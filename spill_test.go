@@ -0,0 +1,155 @@
+package signature
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWithSpillToDisk(t *testing.T) {
+	keys := newTestKeys()
+
+	dir, err := ioutil.TempDir("", "signature-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	spilled := func() int {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+
+	v := keys.verifier(t, WithSpillToDisk(16, dir))
+
+	tcs := []struct {
+		name  string
+		body  string
+		spill int
+	}{
+		{"small body", "tiny", 0},
+		{"threshold body", strings.Repeat("a", 16), 0},
+		{"large body", strings.Repeat("large body ", 1000), 1},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var read string
+			var files int
+			h := v.WrapFunc(func(rw http.ResponseWriter, r *http.Request) {
+				files = spilled()
+				b, _ := ioutil.ReadAll(r.Body)
+				read = string(b)
+			})
+
+			req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", tc.body)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			if rw.Code != 200 {
+				t.Fatal("Wrong status code returned:", rw.Code, rw.Body.String())
+			}
+
+			if read != tc.body {
+				t.Error("handler body did not match")
+			}
+
+			if files != tc.spill {
+				t.Errorf("expected %d spilled files, got %d", tc.spill, files)
+			}
+
+			if n := spilled(); n != 0 {
+				t.Errorf("expected spilled files to be removed, found %d", n)
+			}
+		})
+	}
+
+	t.Run("closed by handler", func(t *testing.T) {
+		h := v.WrapFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if err := r.Body.Close(); err != nil {
+				t.Error("could not close body:", err)
+			}
+
+			if n := spilled(); n != 0 {
+				t.Errorf("expected spilled file to be removed on close, found %d", n)
+			}
+		})
+
+		body := strings.Repeat("large body ", 1000)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		var called bool
+		h := v.WrapFunc(func(http.ResponseWriter, *http.Request) {
+			called = true
+		})
+
+		body := strings.Repeat("large body ", 1000)
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		req.Body = ioutil.NopCloser(strings.NewReader(body + "tampered"))
+
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if called || rw.Code != 401 {
+			t.Error("expected tampered body to be rejected, got:", rw.Code)
+		}
+
+		if n := spilled(); n != 0 {
+			t.Errorf("expected spilled files to be removed, found %d", n)
+		}
+	})
+
+	t.Run("memory bound", func(t *testing.T) {
+		const size = 8 << 20
+		body := strings.Repeat("large body ", size/11)
+
+		var read int64
+		h := v.WrapFunc(func(rw http.ResponseWriter, r *http.Request) {
+			read, _ = io.Copy(ioutil.Discard, r.Body)
+		})
+		req := keys.newSignedReq(t, "PUT", "https://127.0.0.1:4567/v1/resources", body)
+		rw := httptest.NewRecorder()
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		h.ServeHTTP(rw, req)
+		runtime.ReadMemStats(&after)
+
+		if rw.Code != 200 || read != int64(len(body)) {
+			t.Fatal("expected large body to verify, got:", rw.Code, rw.Body.String())
+		}
+
+		// Neither buffering nor verification holds the body in memory.
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/8 {
+			t.Errorf("expected less than %d bytes to be allocated, got %d", size/8, alloc)
+		}
+	})
+
+	t.Run("buffered options", func(t *testing.T) {
+		v := keys.verifier(t, WithSpillToDisk(16, dir), WithLengthPrefixedBody())
+
+		body := strings.Repeat("large body ", 1000)
+		req, _ := http.NewRequest("PUT", "https://127.0.0.1:4567/v1/resources", strings.NewReader(body))
+		req.Header.Set("Date", "2017-03-05T23:53:08Z")
+		req.Header.Set("X-Signed-Headers", "host date")
+		b, _ := v.canon.canonize(req, strings.NewReader(body))
+		keys.sign(req, b)
+
+		rw := httptest.NewRecorder()
+		v.WrapFunc(func(http.ResponseWriter, *http.Request) {}).ServeHTTP(rw, req)
+		if rw.Code != 200 {
+			t.Error("expected spilled body to verify with a length prefix, got:", rw.Code, rw.Body.String())
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package signature
+
+import "strings"
+
+// parseStructuredList parses an RFC 8941 structured field inner list of header
+// names, such as `("host" "date" "content-type")`, returning the lowercased
+// names. Names may be strings or tokens. Parameters, on the list or its items,
+// are ignored.
+func parseStructuredList(s string) ([]string, bool) {
+	s = strings.Trim(s, " \t")
+	if s == "" || s[0] != '(' {
+		return nil, false
+	}
+	s = s[1:]
+
+	var names []string
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return nil, false
+		}
+		if s[0] == ')' {
+			s = s[1:]
+			break
+		}
+
+		var name string
+		var ok bool
+		if s[0] == '"' {
+			name, s, ok = parseSFString(s)
+		} else {
+			name, s, ok = parseSFToken(s)
+		}
+		if !ok {
+			return nil, false
+		}
+		names = append(names, strings.ToLower(name))
+
+		s = skipSFParams(s)
+		if s != "" && s[0] != ' ' && s[0] != ')' {
+			return nil, false
+		}
+	}
+
+	if s = skipSFParams(s); s != "" {
+		return nil, false
+	}
+
+	return names, true
+}
+
+// parseSFString parses the structured field string at the start of s,
+// returning its value and the remainder of s.
+func parseSFString(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return b.String(), s[i+1:], true
+		case c == '\\':
+			i++
+			if i == len(s) || (s[i] != '"' && s[i] != '\\') {
+				return "", "", false
+			}
+			b.WriteByte(s[i])
+		case c < 0x20 || c > 0x7e:
+			return "", "", false
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", "", false
+}
+
+// parseSFToken parses the structured field token at the start of s, returning
+// it and the remainder of s.
+func parseSFToken(s string) (string, string, bool) {
+	if c := s[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '*') {
+		return "", "", false
+	}
+
+	i := 1
+	for i < len(s) && isSFTokenChar(s[i]) {
+		i++
+	}
+
+	return s[:i], s[i:], true
+}
+
+// isSFTokenChar reports whether c may appear in a structured field token after
+// its first character.
+func isSFTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+
+	return strings.IndexByte("!#$%&'*+-.^_`|~:/", c) >= 0
+}
+
+// skipSFParams returns s with any structured field parameters at its start
+// removed.
+func skipSFParams(s string) string {
+	for len(s) > 0 && s[0] == ';' {
+		i := 1
+		for i < len(s) && s[i] != ';' && s[i] != ' ' && s[i] != ')' {
+			if s[i] == '"' {
+				_, rest, ok := parseSFString(s[i:])
+				if !ok {
+					return s
+				}
+				i = len(s) - len(rest)
+				continue
+			}
+			i++
+		}
+		s = s[i:]
+	}
+
+	return s
+}
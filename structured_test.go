@@ -0,0 +1,41 @@
+package signature
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStructuredList(t *testing.T) {
+	tcs := []struct {
+		in       string
+		expected []string
+		ok       bool
+	}{
+		{`("host" "date" "content-type")`, []string{"host", "date", "content-type"}, true},
+		{` ( "Host"  "Date" ) `, []string{"host", "date"}, true},
+		{`(host date)`, []string{"host", "date"}, true},
+		{`("host";req "date");alg="ed25519"`, []string{"host", "date"}, true},
+		{`("x-\"quoted\\")`, []string{`x-"quoted\`}, true},
+		{`()`, nil, true},
+		{`host date`, nil, false},
+		{`("host" "date"`, nil, false},
+		{`("host""date")`, nil, false},
+		{`("host" 1date)`, nil, false},
+		{`("host") extra`, nil, false},
+		{`("ho\st")`, nil, false},
+		{``, nil, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			names, ok := parseStructuredList(tc.in)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %t, got %t", tc.ok, ok)
+			}
+
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("unexpected names: %q", names)
+			}
+		})
+	}
+}
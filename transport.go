@@ -0,0 +1,88 @@
+package signature
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// RequestSigner signs outgoing requests. Sign sets the signature headers on
+// req, over the given body, modifying req in place. It is implemented by
+// *Signer.
+type RequestSigner interface {
+	Sign(req *http.Request, body io.Reader) error
+}
+
+// SigningTransport is an http.RoundTripper that signs each request with Signer
+// before sending it with Base.
+//
+// The request body is buffered in memory, as the signature covers all of it.
+// The request sent by Base has GetBody set, so it may be resent by Base; a
+// request redirected by an http.Client is signed again.
+type SigningTransport struct {
+	// Signer signs each request.
+	Signer RequestSigner
+
+	// Base sends the signed requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// SignedHeaders are the names of the headers listed in the X-Signed-Headers
+	// header of each request. If empty, the request's own X-Signed-Headers
+	// header is used, or DefaultSignedHeaders if it has none.
+	SignedHeaders []string
+}
+
+// RoundTrip implements http.RoundTripper, signing req and sending it with
+// Base. req is not modified, other than its body being read and closed.
+func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A RoundTripper must not modify the request it is given, so the
+	// signature is set on a copy.
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header)+3)
+	for k, vs := range req.Header {
+		r.Header[k] = append([]string(nil), vs...)
+	}
+
+	if len(t.SignedHeaders) > 0 {
+		names := make([]string, len(t.SignedHeaders))
+		for i, n := range t.SignedHeaders {
+			names[i] = strings.ToLower(n)
+		}
+		r.Header.Set("X-Signed-Headers", strings.Join(names, " "))
+	}
+
+	if err := t.Signer.Sign(r, bytes.NewReader(body)); err != nil {
+		return nil, err
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		r.ContentLength = int64(len(body))
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	return t.base().RoundTrip(r)
+}
+
+// base returns the RoundTripper used to send signed requests.
+func (t *SigningTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
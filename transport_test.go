@@ -0,0 +1,100 @@
+package signature
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manifoldco/go-base64"
+)
+
+func TestSigningTransport(t *testing.T) {
+	keys := newTestKeys()
+	v := keys.verifier(t)
+
+	var signedHeaders string
+	var bodies []string
+	srv := httptest.NewServer(v.WrapFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/old" {
+			http.Redirect(rw, r, "/v1/new", http.StatusTemporaryRedirect)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		signedHeaders = r.Header.Get("X-Signed-Headers")
+	}))
+	defer srv.Close()
+
+	newClient := func(names ...string) *http.Client {
+		return &http.Client{Transport: &SigningTransport{
+			Signer:        NewSigner(keys.live, base64.New(keys.endorsement)),
+			SignedHeaders: names,
+		}}
+	}
+
+	t.Run("get", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient().Get(srv.URL + "/v1/resources")
+		if err != nil {
+			t.Fatal("could not send request:", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Error("unexpected response code:", resp.StatusCode)
+		}
+		if signedHeaders != DefaultSignedHeaders {
+			t.Error("unexpected signed headers:", signedHeaders)
+		}
+	})
+
+	t.Run("post with signed headers", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient("Host", "Date", "Content-Type").Post(srv.URL+"/v1/resources", "application/json", bytes.NewBufferString("{}"))
+		if err != nil {
+			t.Fatal("could not send request:", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Error("unexpected response code:", resp.StatusCode)
+		}
+		if signedHeaders != "host date content-type" {
+			t.Error("unexpected signed headers:", signedHeaders)
+		}
+		if len(bodies) != 1 || bodies[0] != "{}" {
+			t.Error("unexpected request bodies:", bodies)
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient().Post(srv.URL+"/v1/old", "application/json", bytes.NewBufferString("{}"))
+		if err != nil {
+			t.Fatal("could not send request:", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Error("expected redirected request to verify, got:", resp.StatusCode)
+		}
+		if len(bodies) != 1 || bodies[0] != "{}" {
+			t.Error("unexpected request bodies:", bodies)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/v1/resources")
+		if err != nil {
+			t.Fatal("could not send request:", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Error("expected unsigned request to be rejected, got:", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+package signature
+
+// Version is the version of this package. Options that change the canonical
+// form note the version that added them, so that signers can tell whether a
+// Verifier supports the form they sign.
+const Version = "1.1.0"
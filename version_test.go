@@ -0,0 +1,12 @@
+package signature
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if !regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`).MatchString(Version) {
+		t.Errorf("expected a semantic version, got %q", Version)
+	}
+}
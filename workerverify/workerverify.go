@@ -0,0 +1,73 @@
+//go:build workers
+// +build workers
+
+// Package workerverify verifies signed requests received by Cloudflare
+// Workers, and other edge runtimes built around the fetch API.
+//
+// The Worker's JavaScript passes the request to Go as a Request, typically
+// serialized as JSON. The package does not use syscall/js or any net/http
+// server features, so it builds for GOOS=js GOARCH=wasm, wasip1, and tinygo
+// wasm targets.
+//
+// It is only built with the "workers" build tag, so that it is not compiled by
+// other users of this module.
+package workerverify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/manifoldco/go-signature"
+)
+
+// Request is a fetch API request, as received by a Worker's fetch event
+// handler. It serializes to the JSON produced by:
+//
+//	{
+//	  method: request.method,
+//	  url: request.url,
+//	  headers: [...request.headers],
+//	  body: btoa(String.fromCharCode(...new Uint8Array(await request.arrayBuffer()))),
+//	}
+//
+// The fetch API combines repeated headers into a single comma separated value,
+// and lowercases header names. Neither changes the canonical form, as header
+// names are matched without case, and repeated values are joined with ", ".
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers [][2]string `json:"headers"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+// Parse reads a JSON encoded Request from r.
+func Parse(r io.Reader) (*Request, error) {
+	req := &Request{}
+	if err := json.NewDecoder(r).Decode(req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// Event returns the request as a signature.WebhookEvent.
+func (r *Request) Event() signature.WebhookEvent {
+	header := http.Header{}
+	for _, h := range r.Headers {
+		header.Add(h[0], h[1])
+	}
+
+	return signature.WebhookEvent{
+		Method: r.Method,
+		URL:    r.URL,
+		Header: header,
+		Body:   r.Body,
+	}
+}
+
+// Verify verifies the signature of the request with v.
+func Verify(v *signature.Verifier, r *Request) error {
+	ev := r.Event()
+	return ev.Verify(v)
+}
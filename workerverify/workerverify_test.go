@@ -0,0 +1,112 @@
+//go:build workers
+// +build workers
+
+package workerverify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+
+	base64url "github.com/manifoldco/go-base64"
+	"github.com/manifoldco/go-signature"
+)
+
+// workersRequest returns req as the fetch API presents it to a Worker, with
+// lowercase header names and repeated values combined.
+func workersRequest(req *http.Request, body []byte) *Request {
+	r := &Request{Method: req.Method, URL: req.URL.String(), Body: body}
+	r.Headers = append(r.Headers, [2]string{"host", req.URL.Host})
+	for k, vs := range req.Header {
+		r.Headers = append(r.Headers, [2]string{strings.ToLower(k), strings.Join(vs, ", ")})
+	}
+
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	master := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	live := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	livePub := live.Public().(ed25519.PublicKey)
+
+	v, err := signature.NewVerifier(base64url.New(master.Public().(ed25519.PublicKey)).String(),
+		signature.WithSkewByPath(map[string]time.Duration{"/": 100 * 365 * 24 * time.Hour}))
+	if err != nil {
+		t.Fatal("could not create verifier:", err)
+	}
+
+	s := signature.NewSigner(live, base64url.New(ed25519.Sign(master, livePub)))
+
+	body := []byte(`{"plan":"low"}`)
+	req, _ := http.NewRequest("PUT", "https://example.com/v1/resources?tag=b&tag=a", bytes.NewReader(body))
+	req.Header.Set("Date", "2017-03-05T23:53:08Z")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("X-Tag", "one")
+	req.Header.Add("X-Tag", "two")
+	req.Header.Set("X-Signed-Headers", "host date content-type x-tag")
+	if err := s.Sign(req, bytes.NewReader(body)); err != nil {
+		t.Fatal("could not sign request:", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		if err := Verify(v, workersRequest(req, body)); err != nil {
+			t.Error("expected request to verify, got:", err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(workersRequest(req, body))
+		if err != nil {
+			t.Fatal("could not encode request:", err)
+		}
+
+		r, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal("could not parse request:", err)
+		}
+
+		if err := Verify(v, r); err != nil {
+			t.Error("expected request to verify, got:", err)
+		}
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		if err := Verify(v, workersRequest(req, []byte(`{"plan":"high"}`))); err == nil {
+			t.Error("expected tampered body to fail verification")
+		}
+	})
+
+	t.Run("tampered header", func(t *testing.T) {
+		r := workersRequest(req, body)
+		for i, h := range r.Headers {
+			if h[0] == "x-tag" {
+				r.Headers[i][1] = "one"
+			}
+		}
+
+		if err := Verify(v, r); err == nil {
+			t.Error("expected tampered header to fail verification")
+		}
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		r := workersRequest(req, body)
+		for i, h := range r.Headers {
+			if h[0] == "x-signature" {
+				r.Headers = append(r.Headers[:i], r.Headers[i+1:]...)
+				break
+			}
+		}
+
+		err := Verify(v, r)
+		if e, ok := err.(*signature.Error); !ok || !errors.Is(e, signature.ErrMissingSignature) {
+			t.Error("expected missing signature error, got:", err)
+		}
+	})
+}